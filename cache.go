@@ -0,0 +1,298 @@
+package medusa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Bumped whenever the layout of the cache changes,
+// which invalidates every existing cache.
+const cacheVersion = 2
+
+const cacheManifestName = "manifest.json"
+
+// The state of a build as persisted in the cache directory.
+type cacheManifest struct {
+	Version     int
+	Destination string
+
+	// Maps source paths to the hash of their content.
+	Sources map[string]string
+	// Maps output paths to the hash of their content.
+	Outputs map[string]string
+	// Maps the key of a file transformer invocation
+	// to what it produced.
+	Results map[string]cacheResult
+}
+
+// The outcome of running a [FileTransformer] on one file.
+type cacheResult struct {
+	Path    string
+	Content string // hash of the content, stored as an object
+	// Set if the transformer left the content as it was. No
+	// object is stored, and the content of the input is reused.
+	Unchanged bool `json:",omitempty"`
+}
+
+// buildCache makes builds incremental. It remembers the results of
+// file transformers, so they are only run for files that changed,
+// and which outputs were written, so unchanged outputs are not
+// rewritten and outputs of deleted sources are removed.
+//
+// Results are keyed on the transformer's position in the stack and
+// the file's path, content and frontmatter. File transformers used
+// with a cache must therefore only depend on those, and only the
+// resulting path and content are replayed from the cache. The cache
+// directory should be removed after changing the transformer stack.
+type buildCache struct {
+	dir string
+	log *slog.Logger
+
+	// The manifest of the last successful build.
+	prev cacheManifest
+	// The manifest of the current build.
+	next cacheManifest
+
+	mu sync.Mutex
+}
+
+func newManifest(destination string) cacheManifest {
+	return cacheManifest{
+		Version:     cacheVersion,
+		Destination: destination,
+		Sources:     make(map[string]string),
+		Outputs:     make(map[string]string),
+		Results:     make(map[string]cacheResult),
+	}
+}
+
+// loadCache reads the manifest in dir. A missing or unreadable
+// manifest, or one written for another destination, results in an
+// empty cache so the build starts from scratch.
+func loadCache(dir string, destination string, log *slog.Logger) *buildCache {
+	c := &buildCache{
+		dir:  dir,
+		log:  log,
+		prev: newManifest(destination),
+		next: newManifest(destination),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, cacheManifestName))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Warn("Failed to read build cache, rebuilding everything", "cache_dir", dir, "error", err)
+		}
+		return c
+	}
+
+	var manifest cacheManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		log.Warn("Failed to parse build cache, rebuilding everything", "cache_dir", dir, "error", err)
+		return c
+	}
+	if manifest.Version != cacheVersion || manifest.Destination != destination {
+		log.Info("Build cache is outdated, rebuilding everything", "cache_dir", dir)
+		return c
+	}
+	c.prev = manifest
+	return c
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *buildCache) objectPath(hash string) string {
+	return filepath.Join(c.dir, "objects", hash[:2], hash)
+}
+
+func (c *buildCache) recordSource(path string, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next.Sources[path] = hash
+}
+
+// resultKey identifies a file transformer invocation on
+// file, whose content has the given hash.
+func resultKey(index int, file *File, hash string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%v", index, file.Path, hash, file.Frontmatter)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookup returns the cached result for key, with its content, which
+// is read once it is needed. The content is unset if the result left
+// the content of the input unchanged.
+func (c *buildCache) lookup(key string) (cacheResult, lazyContent, bool) {
+	c.mu.Lock()
+	result, ok := c.prev.Results[key]
+	c.mu.Unlock()
+	if !ok {
+		return cacheResult{}, lazyContent{}, false
+	}
+	if result.Unchanged {
+		c.mu.Lock()
+		c.next.Results[key] = result
+		c.mu.Unlock()
+		return result, lazyContent{}, true
+	}
+
+	objectPath := c.objectPath(result.Content)
+	info, err := os.Stat(objectPath)
+	if err != nil {
+		c.log.Warn("Failed to read cached result", "key", key, "error", err)
+		return cacheResult{}, lazyContent{}, false
+	}
+
+	c.mu.Lock()
+	c.next.Results[key] = result
+	c.mu.Unlock()
+	content := lazyContent{
+		open: func() (io.ReadCloser, error) { return os.Open(objectPath) },
+		size: info.Size(),
+		hash: result.Content,
+	}
+	return result, content, true
+}
+
+// store saves the result of a transformer for key. If the content is
+// still inputHash, the hash of the input, no object is written.
+func (c *buildCache) store(key string, file *File, inputHash string) error {
+	hash, err := file.contentHash()
+	if err != nil {
+		return err
+	}
+	if hash == inputHash {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.next.Results[key] = cacheResult{Path: file.Path, Content: hash, Unchanged: true}
+		return nil
+	}
+	objectPath := c.objectPath(hash)
+	if _, err := os.Stat(objectPath); err != nil {
+		if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+			return fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		r, err := file.reader()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if err := writeFileAtomic(objectPath, r); err != nil {
+			return fmt.Errorf("failed to write cache object: %w", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next.Results[key] = cacheResult{Path: file.Path, Content: hash}
+	return nil
+}
+
+// unchanged reports whether an output with the given content
+// hash was written by the previous build and is still on disk.
+func (c *buildCache) unchanged(path string, hash string, writePath string) bool {
+	if c.prev.Outputs[path] != hash {
+		return false
+	}
+	_, err := os.Stat(writePath)
+	return err == nil
+}
+
+func (c *buildCache) recordOutput(path string, hash string) {
+	c.next.Outputs[path] = hash
+}
+
+// changedSources counts the sources that were added, modified
+// and removed since the previous build.
+func (c *buildCache) changedSources() (added, modified, removed int) {
+	for path, hash := range c.next.Sources {
+		prevHash, ok := c.prev.Sources[path]
+		if !ok {
+			added++
+		} else if prevHash != hash {
+			modified++
+		}
+	}
+	for path := range c.prev.Sources {
+		if _, ok := c.next.Sources[path]; !ok {
+			removed++
+		}
+	}
+	return added, modified, removed
+}
+
+// save writes the manifest of the current build and removes
+// objects that are no longer referenced by it.
+func (c *buildCache) save() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory %s: %w", c.dir, err)
+	}
+	data, err := json.Marshal(c.next)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache manifest: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(c.dir, cacheManifestName), bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to write cache manifest: %w", err)
+	}
+
+	used := make(map[string]bool, len(c.next.Results))
+	for _, result := range c.next.Results {
+		if !result.Unchanged {
+			used[result.Content] = true
+		}
+	}
+	objectsDir := filepath.Join(c.dir, "objects")
+	return filepath.WalkDir(objectsDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !used[d.Name()] {
+			c.log.Debug("Removing unused cache object", "object", d.Name())
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// clear removes the manifest, so the next build starts from scratch.
+func (c *buildCache) clear() {
+	err := os.Remove(filepath.Join(c.dir, cacheManifestName))
+	if err != nil && !os.IsNotExist(err) {
+		c.log.Warn("Failed to clear build cache", "cache_dir", c.dir, "error", err)
+	}
+}
+
+// writeFileAtomic writes r to a temporary file next
+// to path and renames it into place.
+func writeFileAtomic(path string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
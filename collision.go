@@ -0,0 +1,107 @@
+package medusa
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutputCollision indicates that several files would be written
+// to the same path, and Config.CollisionPolicy is CollisionError.
+var ErrOutputCollision = errors.New("files collide at output path")
+
+// Decides what happens when several files would be written to the
+// same path in the destination. Paths that only differ in case are
+// treated as the same path, since they collide on case-insensitive
+// file systems, such as the defaults of macOS and Windows.
+type CollisionPolicy int
+
+const (
+	// The build fails with ErrOutputCollision,
+	// naming every file involved.
+	CollisionError CollisionPolicy = iota
+
+	// The file last in the file slice is written,
+	// and the others are left out of the build.
+	CollisionOverride
+
+	// The file first in the file slice is written,
+	// and the others are left out of the build.
+	CollisionKeepFirst
+)
+
+// collisionKey returns the key under which paths that
+// collide on a case-insensitive file system are the same.
+func collisionKey(path string) string {
+	return strings.ToLower(filepath.ToSlash(filepath.Clean(path)))
+}
+
+// describeSource names the origin of file in collision errors.
+func describeSource(file *File) string {
+	if file.Virtual() {
+		return fmt.Sprintf("%s (generated)", file.Path)
+	}
+	if file.Path != file.sourcePath {
+		return fmt.Sprintf("%s (from %s)", file.Path, file.sourcePath)
+	}
+	return file.Path
+}
+
+// resolveCollisions finds files written to the same output path, and
+// applies the collision policy to them. It returns the files to write,
+// in their original order.
+func (b *Builder) resolveCollisions(files []File) ([]File, error) {
+	// The indexes of the files at every key, in order.
+	byKey := make(map[string][]int, len(files))
+	var keys []string
+	for i := range files {
+		key := collisionKey(files[i].Path)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], i)
+	}
+
+	var errs []error
+	dropped := make(map[int]bool)
+	for _, key := range keys {
+		indexes := byKey[key]
+		if len(indexes) < 2 {
+			continue
+		}
+		sources := make([]string, len(indexes))
+		for i, index := range indexes {
+			sources[i] = describeSource(&files[index])
+		}
+
+		switch b.collisionPolicy {
+		case CollisionOverride:
+			for _, index := range indexes[:len(indexes)-1] {
+				dropped[index] = true
+			}
+			b.log.Warn("Output path collision, keeping the last file", "path", files[indexes[len(indexes)-1]].Path, "files", sources)
+		case CollisionKeepFirst:
+			for _, index := range indexes[1:] {
+				dropped[index] = true
+			}
+			b.log.Warn("Output path collision, keeping the first file", "path", files[indexes[0]].Path, "files", sources)
+		default:
+			errs = append(errs, fmt.Errorf("%w %s: %s", ErrOutputCollision, files[indexes[0]].Path, strings.Join(sources, ", ")))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(dropped) == 0 {
+		return files, nil
+	}
+
+	kept := make([]File, 0, len(files)-len(dropped))
+	for i := range files {
+		if !dropped[i] {
+			kept = append(kept, files[i])
+		}
+	}
+	return kept, nil
+}
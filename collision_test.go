@@ -0,0 +1,91 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// renameMarkdown renames ".md" files to ".html", like a markdown renderer.
+func renameMarkdown(file *File, store *Store) error {
+	if filepath.Ext(file.Path) != ".md" {
+		return nil
+	}
+	file.Path = strings.TrimSuffix(file.Path, ".md") + ".html"
+	return nil
+}
+
+func TestOutputCollision(t *testing.T) {
+	b := NewBuilder()
+	b.SourceFS(fstest.MapFS{
+		"about.html":      {Data: []byte("html")},
+		"about.md":        {Data: []byte("markdown")},
+		"blog/Index.html": {Data: []byte("upper")},
+		"blog/index.html": {Data: []byte("lower")},
+		"other.html":      {Data: []byte("other")},
+	})
+	b.UseFile(renameMarkdown)
+
+	_, err := b.Run(context.Background())
+	if !errors.Is(err, ErrOutputCollision) {
+		t.Fatalf("expected ErrOutputCollision, got %v", err)
+	}
+	for _, want := range []string{
+		"about.html, about.html (from about.md)",
+		filepath.Join("blog", "Index.html") + ", " + filepath.Join("blog", "index.html"),
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "other.html") {
+		t.Errorf("expected error to only name colliding files, got %q", err)
+	}
+}
+
+func TestOutputCollisionGenerated(t *testing.T) {
+	b := NewBuilder()
+	b.SourceFS(fstest.MapFS{"feed.xml": {Data: []byte("source")}})
+	b.Use(func(files *[]File, store *Store) error {
+		*files = append(*files, NewFile("feed.xml", []byte("generated")))
+		return nil
+	})
+
+	_, err := b.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "feed.xml, feed.xml (generated)") {
+		t.Fatalf("expected collision with generated file, got %v", err)
+	}
+}
+
+func TestCollisionPolicy(t *testing.T) {
+	tests := []struct {
+		policy CollisionPolicy
+		want   string
+	}{
+		{CollisionOverride, "markdown"},
+		{CollisionKeepFirst, "html"},
+	}
+	for _, tt := range tests {
+		b := NewBuilder(Config{CollisionPolicy: tt.policy})
+		b.SourceFS(fstest.MapFS{
+			"about.html": {Data: []byte("html")},
+			"about.md":   {Data: []byte("markdown")},
+			"index.html": {Data: []byte("index")},
+		})
+		b.UseFile(renameMarkdown)
+
+		result, err := b.Run(context.Background())
+		if err != nil {
+			t.Fatalf("policy %d: unexpected error: %v", tt.policy, err)
+		}
+		if len(result.Files) != 2 {
+			t.Fatalf("policy %d: expected 2 files, got %d", tt.policy, len(result.Files))
+		}
+		if got := string(result.Files[0].Content()); result.Files[0].Path != "about.html" || got != tt.want {
+			t.Errorf("policy %d: expected about.html with %q, got %s with %q", tt.policy, tt.want, result.Files[0].Path, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package medusa
+
+import "context"
+
+// filteredPlugin applies a plugin to a subset of the files.
+type filteredPlugin struct {
+	wrapper
+	match func(file *File) bool
+	// Set if the filter is invalid, such as for a malformed pattern.
+	err error
+}
+
+// Only applies plugin to the files whose path matches patterns, as
+// reported by [MatchAny], and leaves the other files as they are.
+//
+// The files the plugin is applied to are merged back into the file
+// slice in order. If the plugin keeps the number of files, every file
+// keeps its position. Otherwise, all of them are placed where the
+// first matching file was.
+func Only(patterns []string, plugin Plugin) Plugin {
+	return filterPatterns(patterns, plugin, true)
+}
+
+// Except applies plugin to the files whose path does not match
+// patterns. It is the inverse of [Only].
+func Except(patterns []string, plugin Plugin) Plugin {
+	return filterPatterns(patterns, plugin, false)
+}
+
+// When applies plugin to the files for which cond returns true.
+// Files are merged back as described for [Only].
+func When(cond func(file File) bool, plugin Plugin) Plugin {
+	return filteredPlugin{
+		wrapper: wrapper{plugin: plugin},
+		match:   func(file *File) bool { return cond(*file) },
+	}
+}
+
+func filterPatterns(patterns []string, plugin Plugin, want bool) Plugin {
+	p := filteredPlugin{wrapper: wrapper{plugin: plugin}}
+	for _, pattern := range patterns {
+		if _, err := CompileGlob(pattern); err != nil {
+			p.err = err
+			return p
+		}
+	}
+	p.match = func(file *File) bool {
+		// Patterns were compiled above, so there is no error.
+		match, _ := MatchAny(patterns, file.Path)
+		return match == want
+	}
+	return p
+}
+
+// Name returns the name of the filtered plugin.
+func (p filteredPlugin) Name() string {
+	return pluginName(p.plugin)
+}
+
+func (p filteredPlugin) Transform(ctx context.Context, files *[]File, store *Store) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	var selected, rest []File
+	var positions []int
+	// The number of unselected files before the first selected one.
+	insertAt := -1
+	for i, file := range *files {
+		if p.match(&file) {
+			if insertAt < 0 {
+				insertAt = len(rest)
+			}
+			selected = append(selected, file)
+			positions = append(positions, i)
+		} else {
+			rest = append(rest, file)
+		}
+	}
+
+	// Errors of single files are returned after the
+	// files that did not fail have been merged back.
+	err := p.plugin.Transform(ctx, &selected, store)
+	if _, ok := fileErrors(err); err != nil && !ok {
+		return err
+	}
+
+	if len(selected) == len(positions) {
+		for i, position := range positions {
+			(*files)[position] = selected[i]
+		}
+		return err
+	}
+
+	if insertAt < 0 {
+		insertAt = len(rest)
+	}
+	merged := make([]File, 0, len(rest)+len(selected))
+	merged = append(merged, rest[:insertAt]...)
+	merged = append(merged, selected...)
+	merged = append(merged, rest[insertAt:]...)
+	*files = merged
+	return err
+}
+
+func (p filteredPlugin) fileTransformer() (FileTransformer, bool) {
+	transformer, ok := asFileTransformer(p.plugin)
+	if !ok || p.err != nil {
+		return nil, false
+	}
+	return func(file *File, store *Store) error {
+		if !p.match(file) {
+			return nil
+		}
+		return transformer(file, store)
+	}, true
+}
@@ -0,0 +1,92 @@
+package medusa
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func makeFiles(paths ...string) []File {
+	files := make([]File, len(paths))
+	for i, path := range paths {
+		files[i] = File{Path: path}
+		files[i].SetContent([]byte(path))
+	}
+	return files
+}
+
+func TestOnly(t *testing.T) {
+	files := makeFiles("index.md", "blog/a.md", "style.css", "blog/2024/b.md")
+	store := make(Store)
+
+	upper := FileTransformer(func(file *File, store *Store) error {
+		file.SetContent([]byte(strings.ToUpper(string(file.Content()))))
+		return nil
+	})
+	err := Only([]string{"blog/**/*.md"}, upper).Transform(context.Background(), &files, &store)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, file := range files {
+		got = append(got, string(file.Content()))
+	}
+	want := []string{"index.md", "BLOG/A.MD", "style.css", "BLOG/2024/B.MD"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExceptMergesInOrder(t *testing.T) {
+	files := makeFiles("a.md", "b.css", "c.md", "d.css")
+	store := make(Store)
+
+	// Drops every file and adds a new one
+	replace := Transformer(func(files *[]File, store *Store) error {
+		*files = makeFiles("new.md")
+		return nil
+	})
+	err := Except([]string{"*.css"}, replace).Transform(context.Background(), &files, &store)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := filePaths(files)
+	want := []string{"new.md", "b.css", "d.css"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWhen(t *testing.T) {
+	files := makeFiles("a.md", "b.md")
+	files[1].Frontmatter = Store{"draft": true}
+	store := make(Store)
+
+	drop := Transformer(func(files *[]File, store *Store) error {
+		*files = nil
+		return nil
+	})
+	isDraft := func(file File) bool { return file.Frontmatter["draft"] == true }
+	err := When(isDraft, drop).Transform(context.Background(), &files, &store)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := filePaths(files); !slices.Equal(got, []string{"a.md"}) {
+		t.Errorf("got %v, want %v", got, []string{"a.md"})
+	}
+}
+
+func TestOnlyInvalidPattern(t *testing.T) {
+	files := makeFiles("a.md")
+	store := make(Store)
+	noop := Transformer(func(files *[]File, store *Store) error { return nil })
+
+	err := Only([]string{"[a-"}, noop).Transform(context.Background(), &files, &store)
+	if err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+}
@@ -2,6 +2,7 @@ package medusa
 
 import (
 	"log/slog"
+	"time"
 )
 
 type Config struct {
@@ -27,6 +28,106 @@ type Config struct {
 	//
 	// Optional. Defaults to false.
 	SkipFrontmatterParsing bool
+
+	// Patterns of paths in the source, in the syntax described
+	// at [Glob], of the files whose frontmatter is parsed. An
+	// extension such as ".md" matches files with that extension
+	// in any directory. Patterns are applied like [MatchAny].
+	// Binary files are never parsed, whatever the patterns.
+	//
+	// Optional. The frontmatter of every text file is parsed if empty.
+	FrontmatterPatterns []string
+
+	// The maximum number of files transformed at
+	// once by a [FileTransformer].
+	//
+	// Optional. Defaults to runtime.GOMAXPROCS(0) if zero.
+	Concurrency int
+
+	// Directory, relative to WorkingDir, in which a
+	// build cache is kept. With a cache, file transformers
+	// only run on files that changed since the last build,
+	// and unchanged outputs are linked from the previous
+	// destination instead of being written again.
+	//
+	// Optional. The cache is disabled if empty.
+	CacheDir string
+
+	// How often [Builder.Watch] checks the source
+	// directory for changes.
+	//
+	// Optional. Defaults to 500 milliseconds if zero.
+	WatchInterval time.Duration
+
+	// Patterns of paths in the source to leave out of the
+	// build, in the syntax of .gitignore files. They are
+	// combined with the patterns in the .medusaignore file
+	// at the root of the source, if there is one. Ignored
+	// directories are not walked at all.
+	//
+	// Optional.
+	Ignore []string
+
+	// Path, relative to WorkingDir, to write a [BuildReport]
+	// to as JSON after every successful build.
+	//
+	// Optional. No report is written if empty.
+	ReportFile string
+
+	// Decides what happens when several directories mounted
+	// with [Builder.Mount] contain a file at the same path.
+	//
+	// Optional. Defaults to MountOverride, where the
+	// directory mounted last wins.
+	MountPolicy MountPolicy
+
+	// Decides what happens when several files would be written
+	// to the same path in the destination, including paths that
+	// only differ in case.
+	//
+	// Optional. Defaults to CollisionError, which fails the build.
+	CollisionPolicy CollisionPolicy
+
+	// Whether builds should be reproducible, so that two builds
+	// of the same source produce identical directories and
+	// archives. Files are ordered by path, and every output is
+	// written with the mode 0644, or 0755 if it is executable,
+	// and Timestamp as its modification time.
+	//
+	// Optional. Defaults to false.
+	Reproducible bool
+
+	// Whether the build keeps going when single files fail to
+	// be read or transformed, so every broken file is reported in
+	// one build. Failing files are left out of the rest of the
+	// build, which fails at the end with a [FileError] for every
+	// one of them, joined with [errors.Join]. Transformers report
+	// failing files by returning file errors.
+	//
+	// Optional. Defaults to false.
+	CollectErrors bool
+
+	// The modification time of every output
+	// of a reproducible build.
+	//
+	// Optional. Defaults to the time in the SOURCE_DATE_EPOCH
+	// environment variable, or the Unix epoch if it is not set.
+	Timestamp time.Time
+
+	// The URL the destination is published at, such as
+	// "https://example.com/blog". [File.URL] prefixes the
+	// path of files with it.
+	//
+	// Optional. URLs are relative to the root of the site if empty.
+	BaseURL string
+
+	// Values the store of every build starts with. Each build
+	// gets its own copy of the map, so the values a build adds or
+	// replaces are not seen by the next one. The values themselves
+	// are shared, and should not be changed by transformers.
+	//
+	// Optional.
+	Store Store
 }
 
 // Helper function to set default values
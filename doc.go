@@ -7,10 +7,92 @@ a pointer to the global [Store], is passed to every [Transformer]
 sequencially. At the end of the chain, it writes the state of the
 [File] slice to the destination.
 
+[Builder.BuildContext] accepts a context that aborts the build when
+it is done. Transformers that want to observe the context can be
+added as a [ContextTransformer] with [Builder.UseContext].
+
+[Builder.UsePlugin] accepts any [Plugin]. Plugins implementing
+[Namer] are identified by name in logs, errors and reports, and
+plugins implementing [Lifecycle] are set up before and torn down
+after every build. [Named] gives a name to a plain transformer function.
+
+[Only], [Except] and [When] apply a plugin to a subset of the
+files, for example to render markdown in a single directory:
+
+	b.UsePlugin(medusa.Only([]string{"blog/**"}, markdown.NewFile()))
+
+Instead of a directory, the source can be any [fs.FS], such as an
+[embed.FS], set with [Builder.SourceFS].
+
+Several directories can be overlaid into one source tree with
+[Builder.Mount], for example a theme and the content of a site:
+
+	b.Mount("theme", "")
+	b.Mount("content", "")
+	b.Mount("shared/assets", "assets")
+
+When mounts contain a file at the same path, the mount added last
+wins, unless Config.MountPolicy is [MountError]. Each [File] records
+the [Mount] it was read from.
+
+Builds fail with [ErrOutputCollision] if several files would be
+written to the same path, such as "about.md" rendered next to an
+existing "about.html", or paths that only differ in case. With
+Config.CollisionPolicy, the last or the first of those files is
+written instead.
+
+[Builder.Run] applies the transformers without writing anything,
+and returns the resulting files and store as a [Result].
+
+Files are written to the destination directory, or to a [Sink]
+set with [Builder.Output], such as a zip or tar.gz archive. The
+destination is built in a temporary directory next to it, and only
+replaced once the build succeeded.
+
+With Config.Reproducible, two builds of the same source produce
+identical directories and archives: files are ordered by path, and
+outputs get a normalised mode and a fixed modification time, taken
+from SOURCE_DATE_EPOCH unless Config.Timestamp is set.
+
+A builder can build any number of times, and every build starts
+from the files in the source and a fresh [Store], seeded with
+Config.Store. Concurrent builds on the same builder run one after
+the other.
+
+[Builder.Watch] polls the source directory and rebuilds the
+site whenever files change.
+
+The Path of a file is where it is written in the destination, and
+transformers may change it. [File.SourcePath] keeps the path it was
+read from, and [File.URL] is the URL it is published at, derived
+from its path and Config.BaseURL. Values in the store that refer to
+files can implement [FileResolver] to keep up with renamed files.
+
+Transformers that generate pages, such as feeds or tag indexes,
+create them with [NewFile]. Such files have no source path, and are
+marked as virtual in the [BuildReport].
+
 Each file also has a Frontmatter field where yaml/toml/json
 frontmatter is parsed and stored. The builder returns [ErrFrontmatter]
-if it failes to parse fronmatter of a file. Frontmatter parsing can
-be skipped via [Config].
+if it failes to parse fronmatter of a file. It wraps the error of the
+parser, and tells the format, line and column of the failure, with a
+snippet of the offending lines. Frontmatter parsing can
+be skipped via [Config], or limited to the files matching
+Config.FrontmatterPatterns. Binary files are never parsed.
+
+Only files starting with frontmatter are read while walking the
+source. The content of other files, such as images and videos, is
+read the first time [File.Content] is called, and files whose content
+was never loaded are streamed to the destination.
+
+By default, the build stops at the first error. With
+Config.CollectErrors, files that fail to be read or transformed are
+left out, the build carries on, and it fails at the end with a
+[FileError] for every failing file.
+
+Paths matching the patterns in Config.Ignore, or in a .medusaignore
+file at the root of the source, are left out of the build. Both use
+the syntax of .gitignore files.
 
 Configuration defaults generally rely on Go's zero values (e.g., false for
 booleans, nil for pointers, "" for strings), with specific overrides in
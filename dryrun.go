@@ -0,0 +1,155 @@
+package medusa
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Changes describes how a build would change the
+// destination, as reported by [Builder.DryRun].
+// Every list holds paths relative to the destination,
+// sorted alphabetically.
+type Changes struct {
+	// Files that do not exist in the destination yet.
+	Added []string
+	// Files whose content differs from the destination.
+	Modified []string
+	// Files in the destination the build would not produce.
+	Removed []string
+	// Files whose content is the same as in the destination.
+	Unchanged []string
+}
+
+// DryRun applies the transformers like [Builder.BuildContext], but
+// instead of replacing the destination, it reports how the build
+// would change it. Files are compared by the hash of their content.
+// The destination is never modified, and it may not exist yet.
+func (b *Builder) DryRun(ctx context.Context) (*Changes, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.log.Info("Dry run started", "destination", b.destination)
+	if b.destination == "" {
+		err := fmt.Errorf("destination directory not defined")
+		b.log.Error("Validation failed", "reason", err)
+		return nil, err
+	}
+	err := b.checkSource()
+	if err != nil {
+		b.log.Error("Source check failed", "error", err)
+		return nil, err
+	}
+
+	result, err := b.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := hashDestination(b.destination)
+	if err != nil {
+		b.log.Error("Failed to read destination", "destination", b.destination, "error", err)
+		return nil, err
+	}
+
+	changes := &Changes{}
+	outputs := make(map[string]bool, len(result.Files))
+	for i := range result.Files {
+		path := filepath.Clean(result.Files[i].Path)
+		if outputs[path] {
+			continue
+		}
+		outputs[path] = true
+
+		hash, err := result.Files[i].contentHash()
+		if err != nil {
+			return nil, err
+		}
+		existingHash, ok := existing[path]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case existingHash != hash:
+			changes.Modified = append(changes.Modified, path)
+		default:
+			changes.Unchanged = append(changes.Unchanged, path)
+		}
+	}
+	for path := range existing {
+		if !outputs[path] {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+
+	slices.Sort(changes.Added)
+	slices.Sort(changes.Modified)
+	slices.Sort(changes.Removed)
+	slices.Sort(changes.Unchanged)
+
+	b.log.Info("Dry run finished",
+		"destination", b.destination,
+		"added", len(changes.Added),
+		"modified", len(changes.Modified),
+		"removed", len(changes.Removed),
+		"unchanged", len(changes.Unchanged),
+	)
+	return changes, nil
+}
+
+// hashDestination maps the path of every file in dir to the
+// hash of its content. A missing dir has no files. If dir is a
+// symbolic link, the directory it points to is read.
+func hashDestination(dir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	root, err := filepath.EvalSymlinks(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return hashes, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read destination directory %s: %w", dir, err)
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		hashes[rel] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination directory %s: %w", dir, err)
+	}
+	return hashes, nil
+}
+
+// hashFile returns the hex encoded SHA-256 hash of the file at
+// path, reading it in chunks.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
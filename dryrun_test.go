@@ -0,0 +1,54 @@
+package medusa
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"same.html":    "same",
+		"changed.html": "new",
+		"added.html":   "added",
+	})
+	for path, content := range map[string]string{
+		"same.html":         "same",
+		"changed.html":      "old",
+		"blog/removed.html": "removed",
+	} {
+		full := filepath.Join(dir, "build", path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Destination("build")
+
+	changes, err := b.DryRun(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &Changes{
+		Added:     []string{"added.html"},
+		Modified:  []string{"changed.html"},
+		Removed:   []string{filepath.Join("blog", "removed.html")},
+		Unchanged: []string{"same.html"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "build", "changed.html"))
+	if err != nil || string(content) != "old" {
+		t.Errorf("destination was modified: %q, %v", content, err)
+	}
+}
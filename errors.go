@@ -0,0 +1,59 @@
+package medusa
+
+import "fmt"
+
+// A FileError is the error of a single file. Transformers that keep
+// going after a file failed should return a FileError for every
+// failing file, joined with [errors.Join], and leave those files out
+// of the file slice. With Config.CollectErrors, the builder then
+// collects them and carries on with the next transformer.
+type FileError struct {
+	// The path of the file, as it was before the file failed.
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// fileErrors returns the file errors err consists of. It returns
+// false if err is nil, or if any part of it is not a [FileError].
+func fileErrors(err error) ([]*FileError, bool) {
+	switch e := err.(type) {
+	case nil:
+		return nil, false
+	case *FileError:
+		return []*FileError{e}, true
+	case interface{ Unwrap() []error }:
+		var all []*FileError
+		for _, err := range e.Unwrap() {
+			errs, ok := fileErrors(err)
+			if !ok {
+				return nil, false
+			}
+			all = append(all, errs...)
+		}
+		return all, len(all) > 0
+	}
+	return nil, false
+}
+
+// removeFailedFiles removes the files of errs from files.
+func removeFailedFiles(files *[]File, errs []*FileError) {
+	failed := make(map[string]bool, len(errs))
+	for _, err := range errs {
+		failed[err.Path] = true
+	}
+	remaining := (*files)[:0]
+	for _, file := range *files {
+		if !failed[file.Path] {
+			remaining = append(remaining, file)
+		}
+	}
+	*files = remaining
+}
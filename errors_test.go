@@ -0,0 +1,62 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCollectErrors(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"a.html":      "---\ntitle: [broken\n---\na",
+		"b.html":      "b",
+		"c.html":      "c",
+		"d.html":      "d",
+		"e.html":      "---\ntitle: [broken\n---\ne",
+		"nested/f.md": "f",
+	})
+	errFailed := errors.New("failed")
+
+	b := NewBuilder(Config{WorkingDir: dir, CollectErrors: true})
+	b.Source("src")
+	b.UseFile(func(file *File, store *Store) error {
+		if file.Path == "c.html" || file.Path == "d.html" {
+			return errFailed
+		}
+		return nil
+	})
+	var seen []string
+	b.Use(func(files *[]File, store *Store) error {
+		seen = filePaths(*files)
+		return nil
+	})
+
+	_, err := b.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var paths []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fileErr *FileError
+		if !errors.As(err, &fileErr) {
+			t.Fatalf("expected a file error, got %v", err)
+		}
+		paths = append(paths, fileErr.Path)
+	}
+	if got := strings.Join(paths, ","); got != "a.html,e.html,c.html,d.html" {
+		t.Errorf("unexpected failing files %s", got)
+	}
+	if !errors.Is(err, errFailed) {
+		t.Errorf("expected error to wrap the transformer's error, got %v", err)
+	}
+	var frontmatterErr ErrFrontmatter
+	if !errors.As(err, &frontmatterErr) {
+		t.Errorf("expected error to wrap ErrFrontmatter, got %v", err)
+	}
+
+	if got := strings.Join(seen, ","); got != "b.html,nested/f.md" {
+		t.Errorf("expected failing files to be left out, got %s", got)
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
 
 	"git.sr.ht/~relay/medusa"
 	"git.sr.ht/~relay/medusa/transformers/collections"
@@ -41,13 +43,41 @@ func main() {
 		Patterns: []string{"blog/*.md"},
 	}))
 
-	b.Use(markdown.New())
+	b.UseFile(markdown.NewFile())
 
 	b.Use(layouts.New(layouts.Config{
 		LayoutPatterns:  []string{"template/*"},
 		ContentPatterns: []string{"*.html"},
 	}))
 
+	// "go run . watch" rebuilds on every change until interrupted,
+	// "go run . serve" also serves the site on localhost:8080 and
+	// "go run . dry-run" prints what a build would change.
+	if len(os.Args) > 1 {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		var err error
+		switch os.Args[1] {
+		case "watch":
+			err = b.Watch(ctx)
+		case "serve":
+			err = b.Serve(ctx, "localhost:8080")
+		case "dry-run":
+			var changes *medusa.Changes
+			changes, err = b.DryRun(ctx)
+			if err == nil {
+				fmt.Printf("added: %v\nmodified: %v\nremoved: %v\n", changes.Added, changes.Modified, changes.Removed)
+			}
+		default:
+			log.Fatalf("Unknown command: %v", os.Args[1])
+		}
+		if err != nil {
+			log.Fatalf("%v failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	err := b.Build()
 	if err != nil {
 		if errors.Is(err, medusa.ErrDestinationExists) {
@@ -1,24 +1,25 @@
 package medusa
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/fs"
-	"os"
+	"net/url"
+	"path"
 	"path/filepath"
-
-	"github.com/adrg/frontmatter"
+	"strings"
+	"time"
 )
 
-type ErrFrontmatter struct {
-	path string
-}
-
-func (e ErrFrontmatter) Error() string {
-	return fmt.Sprintf("failed to parse frontmatter at: %v", e.path)
-}
-
 type File struct {
+	// The path the file is written to, relative to the destination.
+	// Transformers may change it, such as to rename "post.md" to
+	// "post.html". The path the file was read from is kept as its
+	// [File.SourcePath].
 	Path     string
 	FileInfo fs.FileInfo
 	Store    Store
@@ -26,67 +27,237 @@ type File struct {
 	// the yaml/toml/json frontmatter of the file
 	Frontmatter Store
 
+	// The mount the file was read from. It is the
+	// zero value for files not read from the source.
+	Mount Mount
+
+	// Set when the file is read, and never changed afterwards.
+	sourcePath string
+	// Config.BaseURL, set by the builder before every transformer.
+	baseURL string
+
 	content []byte
+	// Set while the content was not loaded yet.
+	lazy lazyContent
+	// The error of loading the content.
+	err error
+}
+
+// Where the content of a file is read from,
+// until it is loaded. It is unset once loaded.
+type lazyContent struct {
+	open func() (io.ReadCloser, error)
+	size int64
+	// The hash of the content, once computed.
+	hash string
+}
+
+// NewFile creates a file at path, relative to the destination, for
+// transformers that generate pages such as feeds or tag indexes. The
+// path may be slash separated. The file gets a [NewFileInfo] with the
+// mode 0644, the size of content and the current time, and empty
+// Store and Frontmatter. It is reported as virtual in build reports.
+func NewFile(path string, content []byte) File {
+	path = filepath.FromSlash(path)
+	return File{
+		Path:        path,
+		FileInfo:    NewFileInfo(filepath.Base(path), int64(len(content)), 0644, time.Now()),
+		Store:       make(Store),
+		Frontmatter: make(Store),
+		content:     content,
+	}
+}
+
+// NewFileInfo returns a synthetic [fs.FileInfo] for a file that does
+// not exist on disk. When the content of a file with such a file info
+// is set, its size is updated.
+func NewFileInfo(name string, size int64, mode fs.FileMode, modTime time.Time) fs.FileInfo {
+	return resultFileInfo{name: name, size: size, mode: mode, modTime: modTime}
 }
 
-// Get the contents of the file
+// Virtual reports whether the file was created by a transformer,
+// rather than read from the source.
+func (f File) Virtual() bool {
+	return f.sourcePath == ""
+}
+
+// SourcePath returns the path the file was read from, relative to the
+// source, in the same form as Path. Unlike Path, transformers can not
+// change it. It is empty for files not read from the source.
+func (f File) SourcePath() string {
+	return f.sourcePath
+}
+
+// URL returns the URL the file is published at, derived from its Path
+// and Config.BaseURL. Files named "index.html" get the URL of their
+// directory, so "blog/index.html" is published at "/blog/". Without
+// a base URL, the URL is relative to the root of the site.
+func (f File) URL() string {
+	p := path.Clean("/" + filepath.ToSlash(f.Path))
+	if path.Base(p) == "index.html" {
+		p = strings.TrimSuffix(p, "index.html")
+	}
+	u := url.URL{Path: p}
+	return f.baseURL + u.EscapedPath()
+}
+
+// Get the contents of the file. The contents of files without
+// frontmatter are only read from the source on the first call. If
+// that fails, it returns nil, and the build fails when the file is
+// written.
 func (f *File) Content() []byte {
+	f.load()
 	return f.content
 }
 
-// Set the contents of the file
+// Set the contents of the file. If loading the previous contents
+// failed, the error is kept, and the build still fails for the file.
 func (f *File) SetContent(bytes []byte) {
 	f.content = bytes
+	f.lazy = lazyContent{}
+	f.updateInfoSize()
 }
 
-func (b *Builder) srcWalker(path string, d fs.DirEntry, err error) error {
-	if err != nil {
-		return err
+// updateInfoSize updates the size in a synthetic file info.
+func (f *File) updateInfoSize() {
+	if info, ok := f.FileInfo.(resultFileInfo); ok {
+		info.size = f.size()
+		f.FileInfo = info
 	}
+}
 
-	if d.IsDir() {
-		return nil
+// load reads the content from its source, if it was not read yet.
+func (f *File) load() {
+	if f.lazy.open == nil {
+		return
 	}
-
-	fileinfo, err := d.Info()
+	content, err := readAll(f.lazy.open)
+	f.lazy = lazyContent{}
+	f.content = content
 	if err != nil {
-		return err
+		f.err = fmt.Errorf("failed to read %s: %w", f.Path, err)
 	}
+}
 
-	file, err := os.Open(path)
-	if err != nil {
-		return err
+// reader returns a reader of the content, without loading it.
+func (f *File) reader() (io.ReadCloser, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.lazy.open != nil {
+		return f.lazy.open()
 	}
-	defer file.Close()
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
 
-	var fm = make(Store)
-	var content []byte
+// size returns the size of the content, without loading it.
+func (f *File) size() int64 {
+	if f.lazy.open != nil {
+		return f.lazy.size
+	}
+	return int64(len(f.content))
+}
 
-	if !b.skipFrontmatter {
-		content, err = frontmatter.Parse(file, &fm)
+// contentHash returns the hash of the content, without loading it.
+func (f *File) contentHash() (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	if f.lazy.open == nil {
+		return hashBytes(f.content), nil
+	}
+	if f.lazy.hash == "" {
+		r, err := f.lazy.open()
 		if err != nil {
-			b.log.Error("failed to parse frontmatter", "file", path)
-			return ErrFrontmatter{path: path}
+			return "", fmt.Errorf("failed to read %s: %w", f.Path, err)
 		}
-	} else {
-		content, err = io.ReadAll(file)
-		if err != nil {
-			return err
+		defer r.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", f.Path, err)
 		}
+		f.lazy.hash = hex.EncodeToString(h.Sum(nil))
+	}
+	return f.lazy.hash, nil
+}
+
+// readAll reads everything from the reader returned by open.
+func readAll(open func() (io.ReadCloser, error)) ([]byte, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
 
-	sourceRelPath, err := filepath.Rel(b.source, path)
+// readSourceFile reads the file at name in mount. Only text files
+// starting with frontmatter, and matching Config.FrontmatterPatterns,
+// are read right away. Others are read once their content is needed,
+// and keep their content byte for byte.
+func (b *Builder) readSourceFile(mount Mount, name string, d fs.DirEntry) (File, error) {
+	fileinfo, err := d.Info()
 	if err != nil {
-		return err
+		return File{}, err
 	}
 
-	b.files = append(b.files, File{
+	file := File{
 		FileInfo:    fileinfo,
-		Path:        sourceRelPath,
+		Path:        filepath.FromSlash(mount.treePath(name)),
+		sourcePath:  filepath.FromSlash(mount.treePath(name)),
+		baseURL:     b.baseURL,
+		Mount:       mount,
 		Store:       make(Store),
-		Frontmatter: fm,
+		Frontmatter: make(Store),
+	}
+	lazy := lazyContent{
+		open: func() (io.ReadCloser, error) { return mount.fsys.Open(name) },
+		size: fileinfo.Size(),
+	}
+	if b.skipFrontmatter {
+		file.lazy = lazy
+		return file, nil
+	}
+	if b.frontmatter != nil {
+		// The patterns were compiled before the walk.
+		if match, _ := MatchAny(b.frontmatter, mount.treePath(name)); !match {
+			file.lazy = lazy
+			return file, nil
+		}
+	}
+
+	src, err := mount.fsys.Open(name)
+	if err != nil {
+		return File{}, err
+	}
+	defer src.Close()
+
+	r := bufio.NewReaderSize(src, frontmatterPeekSize)
+	prefix, err := r.Peek(frontmatterPeekSize)
+	if err != nil && err != io.EOF {
+		return File{}, err
+	}
+	whole := err == io.EOF
+	if !hasFrontmatter(prefix, whole) {
+		file.lazy = lazy
+		return file, nil
+	}
+	if looksBinary(prefix, whole) {
+		b.log.Debug("Not parsing frontmatter of binary file", "file", name, "mount", mount)
+		file.lazy = lazy
+		return file, nil
+	}
 
-		content: content,
-	})
-	return nil
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return File{}, err
+	}
+	file.content, err = parseFrontmatter(raw, &file.Frontmatter)
+	if err != nil {
+		fmErr := err.(ErrFrontmatter)
+		fmErr.Path = mount.sourcePath(name)
+		b.log.Error("failed to parse frontmatter", "file", name, "mount", mount, "line", fmErr.Line, "error", fmErr.Err)
+		return File{}, fmErr
+	}
+	return file, nil
 }
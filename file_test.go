@@ -0,0 +1,270 @@
+package medusa
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHasFrontmatter(t *testing.T) {
+	tests := []struct {
+		prefix string
+		whole  bool
+		want   bool
+	}{
+		{"---\ntitle: x\n---\n", true, true},
+		{"\n\n+++\n", false, true},
+		{"---json\n", false, true},
+		{"{\n", false, true},
+		{"# Heading\n", false, false},
+		{"\x89PNG\r\n\x1a\n", false, false},
+		{"---", true, true},
+		{"--", false, true}, // the line could still be "---"
+		{strings.Repeat("x", 100), false, false},
+		{"", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := hasFrontmatter([]byte(tt.prefix), tt.whole); got != tt.want {
+			t.Errorf("hasFrontmatter(%q, %v) = %v, want %v", tt.prefix, tt.whole, got, tt.want)
+		}
+	}
+}
+
+func TestLazyContent(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"page.html": "---\ntitle: Page\n---\npage",
+		"image.png": "\x89PNG\r\n\x1a\nimage",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := range result.Files {
+		file := &result.Files[i]
+		switch file.Path {
+		case "page.html":
+			if file.lazy.open != nil || string(file.content) != "page" {
+				t.Errorf("expected page.html to be loaded while parsing its frontmatter")
+			}
+		case "image.png":
+			if file.lazy.open == nil || file.content != nil {
+				t.Fatalf("expected image.png not to be loaded")
+			}
+			if file.size() != int64(len("\x89PNG\r\n\x1a\nimage")) {
+				t.Errorf("unexpected size %d", file.size())
+			}
+			if got := string(file.Content()); got != "\x89PNG\r\n\x1a\nimage" {
+				t.Errorf("unexpected content %q", got)
+			}
+			if file.lazy.open != nil {
+				t.Error("expected image.png to be loaded by Content")
+			}
+		}
+	}
+}
+
+func TestLazyContentStreamed(t *testing.T) {
+	dir := makeSource(t, map[string]string{"video.mp4": "video"})
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Destination("build")
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "build", "video.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "video" {
+		t.Errorf("got %q, want %q", got, "video")
+	}
+	if report := b.Report(); report.Outputs[0].Hash != hashBytes([]byte("video")) {
+		t.Errorf("unexpected hash %s", report.Outputs[0].Hash)
+	}
+}
+
+func TestLazyContentMissing(t *testing.T) {
+	rewrite := func(file *File, store *Store) error {
+		file.SetContent(append([]byte("rewritten: "), file.Content()...))
+		return nil
+	}
+	tests := []struct {
+		name string
+		use  func(b *Builder)
+	}{
+		{"file transformer", func(b *Builder) { b.UseFile(rewrite) }},
+		{"transformer", func(b *Builder) {
+			b.Use(func(files *[]File, store *Store) error {
+				for i := range *files {
+					rewrite(&(*files)[i], store)
+				}
+				return nil
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeSource(t, map[string]string{"a.md": "a"})
+
+			b := NewBuilder(Config{WorkingDir: dir})
+			b.Source("src")
+			b.Destination("build")
+			b.Use(func(files *[]File, store *Store) error {
+				return os.Remove(filepath.Join(dir, "src", "a.md"))
+			})
+			tt.use(b)
+
+			err := b.Build()
+			if err == nil || !strings.Contains(err.Error(), "failed to read a.md") {
+				t.Fatalf("expected the read error, got %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "build")); !os.IsNotExist(err) {
+				t.Errorf("expected no destination, stat error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	tests := []struct {
+		path    string
+		baseURL string
+		want    string
+	}{
+		{"index.html", "", "/"},
+		{"about.html", "", "/about.html"},
+		{filepath.Join("blog", "index.html"), "", "/blog/"},
+		{filepath.Join("blog", "my post.html"), "", "/blog/my%20post.html"},
+		{filepath.Join("blog", "index.html"), "https://example.com/site", "https://example.com/site/blog/"},
+		{"feed.xml", "https://example.com", "https://example.com/feed.xml"},
+	}
+	for _, tt := range tests {
+		file := File{Path: tt.path, baseURL: tt.baseURL}
+		if got := file.URL(); got != tt.want {
+			t.Errorf("URL of %q with base %q = %q, want %q", tt.path, tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+func TestSourcePath(t *testing.T) {
+	dir := makeSource(t, map[string]string{"blog/post.md": "post"})
+	b := NewBuilder(Config{WorkingDir: dir, BaseURL: "https://example.com/"})
+	b.Source("src")
+	b.UseFile(func(file *File, store *Store) error {
+		file.Path = strings.TrimSuffix(file.Path, ".md") + ".html"
+		return nil
+	})
+	b.Use(func(files *[]File, store *Store) error {
+		*files = append(*files, File{Path: "feed.xml"})
+		return nil
+	})
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	post, feed := result.Files[0], result.Files[1]
+	if want := filepath.Join("blog", "post.md"); post.SourcePath() != want {
+		t.Errorf("expected source path %q, got %q", want, post.SourcePath())
+	}
+	if want := "https://example.com/blog/post.html"; post.URL() != want {
+		t.Errorf("expected URL %q, got %q", want, post.URL())
+	}
+	if feed.SourcePath() != "" {
+		t.Errorf("expected no source path for a created file, got %q", feed.SourcePath())
+	}
+	if want := "https://example.com/feed.xml"; feed.URL() != want {
+		t.Errorf("expected URL %q, got %q", want, feed.URL())
+	}
+	if got := result.Report.Outputs[0].Source; got != filepath.Join("blog", "post.md") {
+		t.Errorf("expected source in report, got %q", got)
+	}
+}
+
+// pathsBySource records the path of every file by its source path.
+type pathsBySource map[string]string
+
+func (p pathsBySource) ResolveFiles(files []File) {
+	for _, file := range files {
+		p[file.SourcePath()] = file.Path
+	}
+}
+
+func TestFileResolver(t *testing.T) {
+	dir := makeSource(t, map[string]string{"post.md": "post"})
+
+	paths := pathsBySource{}
+	var seen string
+	b := NewBuilder(Config{WorkingDir: dir, Store: Store{"paths": paths}})
+	b.Source("src")
+	b.Use(func(files *[]File, store *Store) error {
+		(*files)[0].Path = "post.html"
+		return nil
+	})
+	b.Use(func(files *[]File, store *Store) error {
+		seen = (*store)["paths"].(pathsBySource)["post.md"]
+		(*files)[0].Path = "post/index.html"
+		return nil
+	})
+	if _, err := b.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if seen != "post.html" {
+		t.Errorf("expected the next transformer to see the renamed path, got %q", seen)
+	}
+	if got := paths["post.md"]; got != "post/index.html" {
+		t.Errorf("expected the final path after the last transformer, got %q", got)
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	file := NewFile("tags/go.html", []byte("go"))
+	if want := filepath.Join("tags", "go.html"); file.Path != want {
+		t.Errorf("expected path %q, got %q", want, file.Path)
+	}
+	if !file.Virtual() {
+		t.Error("expected file to be virtual")
+	}
+	info := file.FileInfo
+	if info.Name() != "go.html" || info.Size() != 2 || info.Mode() != 0644 || info.ModTime().IsZero() {
+		t.Errorf("unexpected file info: %s %d %v %v", info.Name(), info.Size(), info.Mode(), info.ModTime())
+	}
+
+	file.SetContent([]byte("golang"))
+	if file.FileInfo.Size() != 6 {
+		t.Errorf("expected size to follow content, got %d", file.FileInfo.Size())
+	}
+}
+
+func TestNewFileReported(t *testing.T) {
+	dir := makeSource(t, map[string]string{"index.html": "index"})
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Destination("build")
+	b.Use(func(files *[]File, store *Store) error {
+		*files = append(*files, NewFile("tags/go.html", []byte("go")))
+		return nil
+	})
+
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "build", "tags", "go.html"))
+	if err != nil || string(content) != "go" {
+		t.Fatalf("expected generated file to be written, got %q, %v", content, err)
+	}
+	outputs := b.Report().Outputs
+	if outputs[0].Virtual || !outputs[1].Virtual {
+		t.Errorf("expected only the generated file to be virtual, got %+v", outputs)
+	}
+}
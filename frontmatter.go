@@ -0,0 +1,248 @@
+package medusa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"github.com/adrg/frontmatter"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrFrontmatter is returned when the frontmatter of a file can not
+// be parsed. Besides the path, it describes where the frontmatter is
+// broken, as far as the parser of the format reports it.
+type ErrFrontmatter struct {
+	// The path of the file in the source.
+	Path string
+
+	// The format of the frontmatter: "YAML", "TOML" or "JSON".
+	// Empty if the file could not be read.
+	Format string
+
+	// The line and column of the failure in the file, starting
+	// at 1. They are 0 if the parser did not report them. YAML
+	// parsers only report lines.
+	Line   int
+	Column int
+
+	// The lines around the failure, with line numbers and a marker
+	// at the failing line. Empty if the line is not known.
+	Snippet string
+
+	// The error of the parser.
+	Err error
+}
+
+func (e ErrFrontmatter) Error() string {
+	format := "frontmatter"
+	if e.Format != "" {
+		format = e.Format + " frontmatter"
+	}
+	position := e.Path
+	if e.Line > 0 {
+		position += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			position += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	if e.Err == nil {
+		return fmt.Sprintf("failed to parse %s at: %v", format, position)
+	}
+	return fmt.Sprintf("failed to parse %s at: %v: %v", format, position, e.Err)
+}
+
+func (e ErrFrontmatter) Unwrap() error {
+	return e.Err
+}
+
+// A frontmatter format. The delimiters are
+// the same ones adrg/frontmatter detects by default.
+type frontmatterFormat struct {
+	name            string
+	start, end      string
+	unmarshal       frontmatter.UnmarshalFunc
+	unmarshalDelims bool
+	requiresNewLine bool
+}
+
+var frontmatterFormats = []frontmatterFormat{
+	{name: "YAML", start: "---", end: "---", unmarshal: yaml.Unmarshal},
+	{name: "YAML", start: "---yaml", end: "---", unmarshal: yaml.Unmarshal},
+	{name: "TOML", start: "+++", end: "+++", unmarshal: toml.Unmarshal},
+	{name: "TOML", start: "---toml", end: "---", unmarshal: toml.Unmarshal},
+	{name: "JSON", start: ";;;", end: ";;;", unmarshal: json.Unmarshal},
+	{name: "JSON", start: "---json", end: "---", unmarshal: json.Unmarshal},
+	{name: "JSON", start: "{", end: "}", unmarshal: json.Unmarshal, unmarshalDelims: true, requiresNewLine: true},
+}
+
+// The number of bytes read from the start of
+// a file to tell whether it has frontmatter.
+const frontmatterPeekSize = 512
+
+// hasFrontmatter reports whether prefix, the start of a file, begins
+// with the opening delimiter of a frontmatter format, like the parser
+// detects it. If it can not tell, because the first non-empty line is
+// not complete and whole is false, it reports true.
+func hasFrontmatter(prefix []byte, whole bool) bool {
+	for len(prefix) > 0 {
+		line, rest, complete := bytes.Cut(prefix, []byte("\n"))
+		trimmed := bytes.TrimSpace(line)
+		if !complete && !whole {
+			// Unless the line is already longer than any
+			// delimiter, the rest of it could still match.
+			return len(trimmed) <= len("---toml")
+		}
+		if len(trimmed) > 0 {
+			for _, f := range frontmatterFormats {
+				if string(trimmed) == f.start {
+					return true
+				}
+			}
+			return false
+		}
+		prefix = rest
+	}
+	return false
+}
+
+// frontmatterPatterns turns the entries of Config.FrontmatterPatterns
+// into glob patterns. Extensions, such as ".md", become patterns
+// matching them in any directory.
+func frontmatterPatterns(entries []string) []string {
+	var patterns []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, ".") && !strings.ContainsAny(entry, `/*?[{\`) {
+			entry = "**/*" + escapeGlob(entry)
+		}
+		patterns = append(patterns, entry)
+	}
+	return patterns
+}
+
+// looksBinary reports whether prefix, the start of a file, is binary
+// data rather than text: it contains a NUL byte, or is not valid UTF-8.
+// Unless whole is true, a rune cut off at the end of prefix is ignored.
+func looksBinary(prefix []byte, whole bool) bool {
+	if bytes.IndexByte(prefix, 0) >= 0 {
+		return true
+	}
+	if !whole {
+		for i := 1; i < utf8.UTFMax && i <= len(prefix); i++ {
+			start := len(prefix) - i
+			if utf8.RuneStart(prefix[start]) {
+				if !utf8.FullRune(prefix[start:]) {
+					prefix = prefix[:start]
+				}
+				break
+			}
+		}
+	}
+	return !utf8.Valid(prefix)
+}
+
+// parseFrontmatter decodes the frontmatter of raw into v and returns
+// the rest of raw. If it fails, it returns an [ErrFrontmatter]
+// without a path.
+func parseFrontmatter(raw []byte, v any) ([]byte, error) {
+	// The format and data of the frontmatter, once detected.
+	var format string
+	var data []byte
+
+	formats := make([]*frontmatter.Format, len(frontmatterFormats))
+	for i, f := range frontmatterFormats {
+		formats[i] = &frontmatter.Format{
+			Start: f.start,
+			End:   f.end,
+			Unmarshal: func(d []byte, v any) error {
+				format, data = f.name, d
+				return f.unmarshal(d, v)
+			},
+			UnmarshalDelims: f.unmarshalDelims,
+			RequiresNewLine: f.requiresNewLine,
+		}
+	}
+
+	content, err := frontmatter.Parse(bytes.NewReader(raw), v, formats...)
+	if err == nil {
+		return content, nil
+	}
+
+	fmErr := ErrFrontmatter{Format: format, Err: err}
+	line, column := errorPosition(data, err)
+	// The data starts at the first line after the opening delimiter.
+	if offset := bytes.Index(raw, data); line > 0 && data != nil && offset >= 0 {
+		fmErr.Line = bytes.Count(raw[:offset], []byte("\n")) + line
+		fmErr.Column = column
+		fmErr.Snippet = snippet(raw, fmErr.Line, fmErr.Column)
+	}
+	return nil, fmErr
+}
+
+// Matches the line in errors of the YAML parser.
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// errorPosition returns the line and column, starting at
+// 1, at which the parser of a format failed to decode data.
+// They are 0 if the error does not tell.
+func errorPosition(data []byte, err error) (line int, column int) {
+	var tomlErr toml.ParseError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tomlErr):
+		return positionAt(data, tomlErr.Position.Start)
+	case errors.As(err, &syntaxErr):
+		// The offset is just past the offending byte.
+		return positionAt(data, int(syntaxErr.Offset)-1)
+	case errors.As(err, &typeErr):
+		return positionAt(data, int(typeErr.Offset)-1)
+	}
+
+	if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ = strconv.Atoi(match[1])
+	}
+	return line, 0
+}
+
+// positionAt returns the line and column of
+// the byte at offset in data, starting at 1.
+func positionAt(data []byte, offset int) (line int, column int) {
+	if offset < 0 || offset > len(data) {
+		return 0, 0
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// snippet formats the lines around line in raw, marking the line,
+// and the column below it if it is known.
+func snippet(raw []byte, line int, column int) string {
+	lines := strings.Split(string(raw), "\n")
+	if line > len(lines) {
+		return ""
+	}
+	first, last := max(line-2, 1), min(line+1, len(lines))
+	width := len(strconv.Itoa(last))
+
+	var sb strings.Builder
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&sb, "%s %*d | %s\n", marker, width, n, strings.TrimRight(lines[n-1], "\r"))
+		if n == line && column > 0 {
+			fmt.Fprintf(&sb, "  %*s | %s^\n", width, "", strings.Repeat(" ", column-1))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,115 @@
+package medusa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseFrontmatterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		format string
+		line   int
+		column int
+	}{
+		{"yaml", "---\ntitle: ok\ntags: [a, b\n---\ncontent", "YAML", 3, 0},
+		{"toml", "\n+++\ntitle = \"ok\"\ndate = 2024-13-45\n+++\ncontent", "TOML", 4, 8},
+		{"json", ";;;\n{\n  \"title\": \"ok\",\n  \"tags\": [1,,2]\n}\n;;;\ncontent", "JSON", 4, 14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFrontmatter([]byte(tt.raw), &Store{})
+			var fmErr ErrFrontmatter
+			if !errors.As(err, &fmErr) {
+				t.Fatalf("expected ErrFrontmatter, got %v", err)
+			}
+			if fmErr.Format != tt.format || fmErr.Line != tt.line || fmErr.Column != tt.column {
+				t.Errorf("expected %s at %d:%d, got %s at %d:%d", tt.format, tt.line, tt.column, fmErr.Format, fmErr.Line, fmErr.Column)
+			}
+			if errors.Unwrap(err) == nil {
+				t.Error("expected the parser error to be wrapped")
+			}
+			if !strings.Contains(fmErr.Snippet, ">") {
+				t.Errorf("expected the snippet to mark the failing line, got:\n%s", fmErr.Snippet)
+			}
+		})
+	}
+}
+
+func TestLooksBinary(t *testing.T) {
+	tests := []struct {
+		prefix string
+		whole  bool
+		want   bool
+	}{
+		{"---\ntitle: x\n---\n", true, false},
+		{"---\ntitle: \xc3\xa9t\xc3\xa9", true, false},
+		// A rune cut off by the end of the prefix.
+		{"---\ntitle: \xc3", false, false},
+		{"---\ntitle: \xc3", true, true},
+		{"---\n\x00\x01\x02", false, true},
+		{"---\n\xff\xfe", false, true},
+	}
+	for _, tt := range tests {
+		if got := looksBinary([]byte(tt.prefix), tt.whole); got != tt.want {
+			t.Errorf("looksBinary(%q, %v) = %v, want %v", tt.prefix, tt.whole, got, tt.want)
+		}
+	}
+}
+
+func TestFrontmatterPatterns(t *testing.T) {
+	source := fstest.MapFS{
+		"index.md":       {Data: []byte("---\ntitle: Home\n---\nhome")},
+		"blog/post.md":   {Data: []byte("---\ntitle: Post\n---\npost")},
+		"css/site.css":   {Data: []byte("---\n: not yaml [\n---\nbody {}")},
+		"img/logo.png":   {Data: []byte("---\n\x89PNG\x00\x00\x1a\n---\n")},
+		"notes/draft.md": {Data: []byte("---\ntitle: Draft\n---\ndraft")},
+	}
+	b := NewBuilder(Config{FrontmatterPatterns: []string{".md", "!notes/**"}})
+	b.SourceFS(source)
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, file := range result.Files {
+		path := filepath.ToSlash(file.Path)
+		parsed := file.Frontmatter["title"] != nil
+		wantParsed := path == "index.md" || path == "blog/post.md"
+		if parsed != wantParsed {
+			t.Errorf("%s: parsed frontmatter = %v, want %v", path, parsed, wantParsed)
+		}
+		if !wantParsed && !bytes.Equal(file.Content(), source[path].Data) {
+			t.Errorf("%s: content changed to %q", path, file.Content())
+		}
+	}
+}
+
+func TestFrontmatterSkipsBinaryFiles(t *testing.T) {
+	data := []byte("---\n\x00\x01\x02\n---\n")
+	b := NewBuilder()
+	b.SourceFS(fstest.MapFS{"data.bin": {Data: data}})
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := result.Files[0].Content(); !bytes.Equal(got, data) {
+		t.Errorf("content changed to %q", got)
+	}
+}
+
+func TestFrontmatterPatternsInvalid(t *testing.T) {
+	b := NewBuilder(Config{FrontmatterPatterns: []string{"{a,b"}})
+	b.SourceFS(fstest.MapFS{"a.md": {Data: []byte("a")}})
+	if _, err := b.Run(context.Background()); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
@@ -0,0 +1,218 @@
+package medusa
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Glob is a compiled glob pattern. Patterns are matched against
+// slash separated paths, segment by segment:
+//   - "*" matches any sequence of characters except "/".
+//   - "?" matches any single character except "/".
+//   - "[a-z]" matches a character in a class, as in [path.Match].
+//   - "**" as a whole segment matches any number of segments,
+//     including none, so "**/*.md" matches "a.md" and "a/b/c.md".
+//   - "{a,b}" matches either alternative, and may be nested.
+//   - A leading "!" negates the pattern.
+type Glob struct {
+	pattern string
+	negate  bool
+	// The brace expanded alternatives, split into segments.
+	alternatives [][]string
+}
+
+// Compiled patterns by their source.
+var globCache sync.Map
+
+type compiledGlob struct {
+	glob *Glob
+	err  error
+}
+
+// CompileGlob parses pattern. Compiled patterns are cached,
+// so compiling the same pattern again is cheap.
+func CompileGlob(pattern string) (*Glob, error) {
+	if cached, ok := globCache.Load(pattern); ok {
+		compiled := cached.(compiledGlob)
+		return compiled.glob, compiled.err
+	}
+	glob, err := compileGlob(pattern)
+	globCache.Store(pattern, compiledGlob{glob: glob, err: err})
+	return glob, err
+}
+
+func compileGlob(pattern string) (*Glob, error) {
+	g := &Glob{pattern: pattern}
+	expr := pattern
+	if strings.HasPrefix(expr, "!") {
+		g.negate = true
+		expr = expr[1:]
+	}
+
+	expanded, err := expandBraces(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	for _, alternative := range expanded {
+		segments := strings.Split(alternative, "/")
+		for _, segment := range segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+			}
+		}
+		g.alternatives = append(g.alternatives, segments)
+	}
+	return g, nil
+}
+
+// expandBraces returns every alternative described by the
+// brace expressions in pattern, such as "{a,b}".
+func expandBraces(pattern string) ([]string, error) {
+	start := -1
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				return nil, fmt.Errorf("unmatched '}'")
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+
+			var expanded []string
+			prefix, suffix := pattern[:start], pattern[i+1:]
+			for _, option := range splitOptions(pattern[start+1 : i]) {
+				alternatives, err := expandBraces(prefix + option + suffix)
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, alternatives...)
+			}
+			return expanded, nil
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unmatched '{'")
+	}
+	return []string{pattern}, nil
+}
+
+// splitOptions splits the inside of a brace expression
+// on the commas that are not nested in another one.
+func splitOptions(options string) []string {
+	var split []string
+	depth := 0
+	last := 0
+	for i := 0; i < len(options); i++ {
+		switch options[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				split = append(split, options[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(split, options[last:])
+}
+
+// String returns the source of the pattern.
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Negated reports whether the pattern starts with "!".
+func (g *Glob) Negated() bool {
+	return g.negate
+}
+
+// Match reports whether name matches the pattern. For a negated
+// pattern, it reports whether name does not match. The name may
+// use the separator of the operating system.
+func (g *Glob) Match(name string) bool {
+	segments := strings.Split(filepath.ToSlash(name), "/")
+	for _, alternative := range g.alternatives {
+		if matchSegments(alternative, segments) {
+			return !g.negate
+		}
+	}
+	return g.negate
+}
+
+// Match reports whether name matches pattern,
+// using the syntax described at [Glob].
+func Match(pattern string, name string) (bool, error) {
+	glob, err := CompileGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+	return glob.Match(name), nil
+}
+
+// MatchAny reports whether name matches patterns. Patterns are
+// applied in order, and the last one matching name decides: a
+// plain pattern includes it, and a negated one excludes it again.
+// If the first pattern is negated, every name is included to
+// begin with, so []string{"!*.css"} matches anything but
+// stylesheets.
+func MatchAny(patterns []string, name string) (bool, error) {
+	matched := false
+	for i, pattern := range patterns {
+		glob, err := CompileGlob(pattern)
+		if err != nil {
+			return false, err
+		}
+		if i == 0 && glob.negate {
+			matched = true
+		}
+		// A negated glob matches what the plain pattern does not.
+		if glob.Match(name) != glob.negate {
+			matched = !glob.negate
+		}
+	}
+	return matched, nil
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range len(name) + 1 {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
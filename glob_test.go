@@ -0,0 +1,85 @@
+package medusa
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.html", "index.html", true},
+		{"*.html", "blog/index.html", false},
+		{"**/*.html", "index.html", true},
+		{"**/*.html", "blog/2024/index.html", true},
+		{"blog/**", "blog/a/b.md", true},
+		{"blog/**/post.md", "blog/post.md", true},
+		{"blog/**/post.md", "blog/a/b/post.md", true},
+		{"blog/**/post.md", "other/post.md", false},
+		{"*.{md,html}", "index.md", true},
+		{"*.{md,html}", "index.html", true},
+		{"*.{md,html}", "index.css", false},
+		{"{blog,news}/*.{md,txt}", "news/a.txt", true},
+		{"a{b,c{d,e}}f", "acef", true},
+		{"!*.css", "style.css", false},
+		{"!*.css", "index.html", true},
+		{"posts/[0-9]*.md", "posts/1-first.md", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Match(tt.pattern, tt.name)
+		if err != nil {
+			t.Errorf("Match(%q, %q) unexpected error: %v", tt.pattern, tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		want     bool
+	}{
+		{[]string{"*.md", "*.html"}, "index.html", true},
+		{[]string{"**/*.md", "!drafts/**"}, "blog/post.md", true},
+		{[]string{"**/*.md", "!drafts/**"}, "drafts/post.md", false},
+		{[]string{"**/*.md", "!drafts/**", "drafts/keep.md"}, "drafts/keep.md", true},
+		{[]string{"!*.css"}, "index.html", true},
+		{[]string{"!*.css"}, "style.css", false},
+		{nil, "index.html", false},
+	}
+
+	for _, tt := range tests {
+		got, err := MatchAny(tt.patterns, tt.name)
+		if err != nil {
+			t.Errorf("MatchAny(%q, %q) unexpected error: %v", tt.patterns, tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MatchAny(%q, %q) = %v, want %v", tt.patterns, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompileGlobInvalid(t *testing.T) {
+	for _, pattern := range []string{"[a-", "{a,b", "a}"} {
+		if _, err := CompileGlob(pattern); err == nil {
+			t.Errorf("CompileGlob(%q) expected an error", pattern)
+		}
+	}
+}
+
+func TestCompileGlobCached(t *testing.T) {
+	a, err := CompileGlob("**/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := CompileGlob("**/*.md")
+	if a != b {
+		t.Error("expected compiled pattern to be cached")
+	}
+}
@@ -5,7 +5,7 @@ go 1.23.0
 require github.com/adrg/frontmatter v0.2.0
 
 require (
-	github.com/BurntSushi/toml v1.2.1 // indirect
+	github.com/BurntSushi/toml v1.2.1
 	github.com/yuin/goldmark v1.7.8
-	gopkg.in/yaml.v2 v2.3.0 // indirect
+	gopkg.in/yaml.v2 v2.3.0
 )
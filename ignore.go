@@ -0,0 +1,164 @@
+package medusa
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// The name of the file in the root of the source
+// that lists paths to leave out of the build.
+const ignoreFileName = ".medusaignore"
+
+// A single line of a .medusaignore file.
+type ignoreRule struct {
+	glob    *Glob
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreRules decides which paths in the source are left out of the
+// build. The syntax is that of .gitignore files:
+//   - Blank lines and lines starting with "#" are skipped.
+//   - A leading "!" re-includes paths excluded by an earlier rule.
+//   - A trailing "/" only matches directories.
+//   - A pattern with a "/" at the start or in the middle is matched
+//     against the whole path, relative to the source root. Any other
+//     pattern is matched against the name of files at any depth.
+//   - "**" matches any number of directories.
+//   - Unlike in [Glob] patterns, braces are literal, and so is a "!"
+//     escaped with a backslash.
+//
+// The last rule matching a path decides whether it is ignored.
+// As with git, files in an ignored directory can not be re-included.
+type ignoreRules []ignoreRule
+
+// parseIgnoreRules parses patterns in the syntax of a .medusaignore
+// file. The patterns are relative to prefix, a slash separated path
+// in the source tree.
+func parseIgnoreRules(lines []string, prefix string) (ignoreRules, error) {
+	var rules ignoreRules
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // escaped "!" or "#"
+		}
+		line = escapeLiterals(line)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		if prefix != "" {
+			line = escapeGlob(prefix) + "/" + line
+		}
+		glob, err := CompileGlob(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore rule: %w", err)
+		}
+
+		rule.glob = glob
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// loadIgnoreRules combines patterns with the rules in the
+// .medusaignore file at the root of fsys, if any. The rules
+// of the file apply to the source tree below prefix.
+func loadIgnoreRules(fsys fs.FS, patterns []string, prefix string) (ignoreRules, error) {
+	rules, err := parseIgnoreRules(patterns, "")
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fsys.Open(ignoreFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", ignoreFileName, err)
+	}
+	defer file.Close()
+
+	lines, err := readLines(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignoreFileName, err)
+	}
+	fileRules, err := parseIgnoreRules(lines, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, fileRules...), nil
+}
+
+// escapeLiterals escapes the characters of an ignore rule that have a
+// meaning in glob patterns, but not in .gitignore files: "!" and the
+// braces and commas of alternatives. Escaped characters are kept.
+func escapeLiterals(rule string) string {
+	var sb strings.Builder
+	escaped := false
+	for _, r := range rule {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case strings.ContainsRune("!{},", r):
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeGlob escapes the characters of s
+// that have a meaning in glob patterns.
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]{},\!`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// ignored reports whether the slash separated path is ignored.
+func (rules ignoreRules) ignored(name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.glob.Match(name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
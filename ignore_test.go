@@ -0,0 +1,108 @@
+package medusa
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := parseIgnoreRules([]string{
+		"# comment",
+		"*.swp",
+		".DS_Store",
+		"drafts/",
+		"/build",
+		"assets/**/*.psd",
+		"*.log",
+		"!keep.log",
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"index.md", false, false},
+		{"index.md.swp", false, true},
+		{"blog/.index.md.swp", false, true},
+		{"blog/.DS_Store", false, true},
+		{"drafts", true, true},
+		{"blog/drafts", true, true},
+		{"drafts", false, false},
+		{"build", true, true},
+		{"blog/build", true, false},
+		{"assets/logo.psd", false, true},
+		{"assets/img/deep/logo.psd", false, true},
+		{"other/logo.psd", false, false},
+		{"debug.log", false, true},
+		{"keep.log", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRulesLiterals(t *testing.T) {
+	rules, err := parseIgnoreRules([]string{`\!foo/bar`, "{a,b}.txt", `\#notes`}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"x", false},
+		{"foo/bar", false},
+		{"!foo/bar", true},
+		{"a.txt", false},
+		{"{a,b}.txt", true},
+		{"#notes", true},
+	}
+	for _, tt := range tests {
+		if got := rules.ignored(tt.path, false); got != tt.want {
+			t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRulesInvalidPattern(t *testing.T) {
+	_, err := parseIgnoreRules([]string{"[a-"}, "")
+	if err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+}
+
+func TestRunIgnore(t *testing.T) {
+	b := NewBuilder(Config{Ignore: []string{"*.swp"}})
+	b.SourceFS(fstest.MapFS{
+		ignoreFileName:          {Data: []byte("node_modules/\n")},
+		"index.md":              {Data: []byte("index")},
+		".index.md.swp":         {Data: []byte("swap")},
+		"node_modules/a/b/c.js": {Data: []byte("dependency")},
+		"js/node_modules/d.js":  {Data: []byte("dependency")},
+		"js/site.js":            {Data: []byte("site")},
+	})
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var paths []string
+	for _, file := range result.Files {
+		paths = append(paths, file.Path)
+	}
+	want := []string{"index.md", "js/site.js"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+}
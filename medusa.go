@@ -1,12 +1,19 @@
 package medusa
 
 import (
+	"context"
 	"errors" // Added for errors.Is and defining new error types
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time" // Import time for duration calculation
 )
 
@@ -15,15 +22,34 @@ import (
 var ErrDestinationExists = errors.New("destination directory exists and overwrite not permitted")
 
 type Builder struct {
-	store Store
+	// Held while a build, run or dry run is in progress,
+	// so concurrent calls run one after the other.
+	mu sync.Mutex
+
+	store Store // seed of the store of every build
 
 	workingDir      string
-	source          string
+	mounts          []Mount
+	mountPolicy     MountPolicy
+	collisionPolicy CollisionPolicy
 	destination     string
-	transformers    []Transformer
+	openSink        func() (Sink, error)
+	transformers    []stage
 	files           []File
 	log             *slog.Logger
 	skipFrontmatter bool
+	frontmatter     []string // patterns of files with frontmatter, nil for all
+	baseURL         string   // without a trailing slash
+	concurrency     int
+	cacheDir        string
+	watchInterval   time.Duration
+	ignore          []string
+	reportFile      string
+	reproducible    bool
+	collectErrors   bool
+	timestamp       time.Time // modification time of outputs in reproducible builds
+	report          atomic.Pointer[BuildReport]
+	cache           *buildCache // only set during a build with a cache
 
 	autoConfirm bool // Represents if overwriting the destination is allowed
 }
@@ -32,9 +58,39 @@ type Builder struct {
 // global store as a part of a larger chain.
 type Transformer func(files *[]File, store *Store) error
 
+// A [Transformer] that also receives the context of the build.
+// Long running transformers should check ctx and return early
+// once it is done.
+type ContextTransformer func(ctx context.Context, files *[]File, store *Store) error
+
+// A transformer that changes a single file. Unlike [Transformer],
+// it can not add or remove files, which allows the builder to run
+// it on several files at once. The store is shared between all
+// files and must only be read.
+type FileTransformer func(file *File, store *Store) error
+
 // Used by transformers to hold arbitrary data.
 type Store map[string]any
 
+// Implemented by values in the [Store] that refer to files, such as
+// collections of pages. Before every transformer, and once the last
+// one ran, the builder calls ResolveFiles with the current files, so
+// the value can update the paths and URLs it holds, for example
+// after a markdown renderer renamed the pages.
+type FileResolver interface {
+	ResolveFiles(files []File)
+}
+
+// resolveStore passes files to the values in
+// store that implement [FileResolver].
+func resolveStore(files []File, store Store) {
+	for _, value := range store {
+		if resolver, ok := value.(FileResolver); ok {
+			resolver.ResolveFiles(files)
+		}
+	}
+}
+
 // Returns empty transformer with error
 func ErrTransformer(err error) Transformer {
 	return func(files *[]File, store *Store) error {
@@ -50,6 +106,19 @@ func ErrTransformer(err error) Transformer {
 // - AllowOverwrite: Defaults to false. If true, allows overwriting the destination.
 // - Logger: Defaults to a discard logger if nil.
 // - SkipFrontmatterParsing: Defaults to false.
+// - FrontmatterPatterns: Defaults to nil, which parses the frontmatter of every text file.
+// - Concurrency: Defaults to runtime.GOMAXPROCS(0) if zero or negative.
+// - CacheDir: Defaults to "", which disables the build cache.
+// - WatchInterval: Defaults to 500 milliseconds if zero or negative.
+// - Ignore: Defaults to nil, which only applies the rules in .medusaignore.
+// - ReportFile: Defaults to "", which disables writing the build report.
+// - MountPolicy: Defaults to MountOverride.
+// - CollisionPolicy: Defaults to CollisionError.
+// - Reproducible: Defaults to false.
+// - CollectErrors: Defaults to false.
+// - Timestamp: Defaults to SOURCE_DATE_EPOCH, or the Unix epoch if it is not set.
+// - BaseURL: Defaults to "", which makes URLs relative to the root of the site.
+// - Store: Defaults to nil, and every build starts with an empty store.
 func NewBuilder(optionalConfig ...Config) *Builder {
 	var config Config
 	if len(optionalConfig) > 0 {
@@ -62,26 +131,65 @@ func NewBuilder(optionalConfig ...Config) *Builder {
 	if config.Logger == nil {
 		config.Logger = slog.New(discardHandler{})
 	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = runtime.GOMAXPROCS(0)
+	}
+	if config.WatchInterval <= 0 {
+		config.WatchInterval = 500 * time.Millisecond
+	}
 
 	logger := config.Logger.With("component", "medusa_builder")
 
 	logger.Debug("Initializing new builder")
 
+	var cacheDir string
+	if config.CacheDir != "" {
+		cacheDir = filepath.Join(config.WorkingDir, config.CacheDir)
+	}
+	if config.Timestamp.IsZero() {
+		config.Timestamp = sourceDateEpoch(logger)
+	}
+
+	var reportFile string
+	if config.ReportFile != "" {
+		reportFile = filepath.Join(config.WorkingDir, config.ReportFile)
+	}
+
 	return &Builder{
 		workingDir:      config.WorkingDir,
 		log:             logger,
 		skipFrontmatter: config.SkipFrontmatterParsing,
+		frontmatter:     frontmatterPatterns(config.FrontmatterPatterns),
+		baseURL:         strings.TrimRight(config.BaseURL, "/"),
+		concurrency:     config.Concurrency,
+		cacheDir:        cacheDir,
+		watchInterval:   config.WatchInterval,
+		ignore:          config.Ignore,
+		reportFile:      reportFile,
 		autoConfirm:     config.AllowOverwrite,
-		store:           make(Store),
+		mountPolicy:     config.MountPolicy,
+		collisionPolicy: config.CollisionPolicy,
+		reproducible:    config.Reproducible,
+		collectErrors:   config.CollectErrors,
+		timestamp:       config.Timestamp,
+		store:           maps.Clone(config.Store),
 	}
 }
 
 // Defines the source directory, relative to WorkingDir
-// as specified in [Config].
+// as specified in [Config]. It replaces the source set
+// before, and every directory mounted with [Builder.Mount].
 func (b *Builder) Source(source string) {
-	absSource := filepath.Join(b.workingDir, source)
-	b.log.Debug("Setting source directory", "raw", source, "absolute", absSource)
-	b.source = absSource
+	b.mounts = nil
+	b.Mount(source, "")
+}
+
+// Defines the source as a file system, such as an [embed.FS],
+// instead of a directory. It replaces the source set before,
+// and every directory mounted with [Builder.Mount].
+func (b *Builder) SourceFS(fsys fs.FS) {
+	b.mounts = nil
+	b.MountFS(fsys, "")
 }
 
 // Defines the destination directory, relative to WorkingDir
@@ -94,179 +202,817 @@ func (b *Builder) Destination(destination string) {
 
 // Adds a transformer function to the stack.
 func (b *Builder) Use(transformer Transformer) {
-	b.log.Debug("Adding transformer", "current_count", len(b.transformers))
-	b.transformers = append(b.transformers, transformer)
+	b.UsePlugin(transformer)
+}
+
+// Adds a context-aware transformer function to the stack.
+func (b *Builder) UseContext(transformer ContextTransformer) {
+	b.UsePlugin(transformer)
+}
+
+// Adds a plugin to the stack, such as one implementing [Namer] or
+// [Lifecycle], or a transformer wrapped with [Named] or [Only].
+//
+// A [FileTransformer] is applied to every file, with up to
+// Config.Concurrency files being transformed at once. If it fails
+// for several files, the error of the first of those files in the
+// file slice is returned. If Config.CacheDir is set, its results
+// are cached, and it is only run on files that changed since the
+// last build. With Config.CollectErrors, every file is transformed
+// even if some fail, and the failing files are left out of the rest
+// of the build.
+func (b *Builder) UsePlugin(plugin Plugin) {
+	s := stage{
+		index:     len(b.transformers),
+		name:      pluginName(plugin),
+		plugin:    plugin,
+		transform: plugin.Transform,
+	}
+	if transformer, ok := asFileTransformer(plugin); ok {
+		s.transform = func(ctx context.Context, files *[]File, store *Store) error {
+			return b.transformFiles(ctx, s.index, transformer, files, store)
+		}
+	}
+	b.log.Debug("Adding transformer", "current_count", len(b.transformers), "name", s.name)
+	b.transformers = append(b.transformers, s)
+}
+
+// Adds a file transformer to the stack. It is applied
+// concurrently, as described at [Builder.UsePlugin].
+func (b *Builder) UseFile(transformer FileTransformer) {
+	b.UsePlugin(transformer)
 }
 
 // Build applies the transformers in the stack to the contents of
 // every file in the source directory, and writes them to the
-// destination. It returns ErrDestinationExists if the destination
-// directory exists and Config.AllowOverwrite is false.
+// destination, or the sink set with [Builder.Output]. It returns
+// ErrDestinationExists if the destination directory exists and
+// Config.AllowOverwrite is false.
 func (b *Builder) Build() error {
+	return b.BuildContext(context.Background())
+}
+
+// BuildContext is like [Builder.Build], but aborts the build once
+// ctx is done and returns the context's error. Cancellation is checked
+// while walking the source directory, between transformers and while
+// writing files.
+//
+// Files are written to a temporary directory next to the destination,
+// which replaces the destination only once every file was written. A
+// failed or aborted build leaves the existing destination untouched.
+// The destination is replaced with two renames, so a server reading
+// it may briefly find it missing between them, but never sees a mix
+// of two builds.
+//
+// A destination that is a symbolic link, or that can not be renamed,
+// such as a mount point, is not replaced. Instead, its contents are
+// replaced by those of the temporary directory, and a warning is
+// logged. The link and the mount are kept, but a server reading the
+// destination may see a partial build while its contents are replaced.
+//
+// A builder can build any number of times. Every build reads the
+// source anew and starts with a fresh store. Builds, runs and dry
+// runs on the same builder are safe to call concurrently, and run
+// one after the other.
+func (b *Builder) BuildContext(ctx context.Context) error {
+	_, err := b.BuildWithReport(ctx)
+	return err
+}
+
+// BuildWithReport is like [Builder.BuildContext], but also returns the
+// report of the build. Unlike [Builder.Report], it is the report of
+// this very build, even if other builds run concurrently.
+func (b *Builder) BuildWithReport(ctx context.Context) (*BuildReport, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	startTime := time.Now()
 	b.log.Info("Build process started")
 	b.log.Debug("Effective configuration",
-		"source", b.source,
+		"mounts", b.mounts,
 		"destination", b.destination,
 		"allow_overwrite", b.autoConfirm,
 		"skip_frontmatter", b.skipFrontmatter,
 		"working_dir", b.workingDir,
+		"cache_dir", b.cacheDir,
 	)
 
 	err := b.checkSourceAndDestination()
 	if err != nil {
 		b.log.Error("Source/Destination check failed", "error", err)
-		return err
+		return nil, err
+	}
+
+	if b.openSink == nil {
+		err = b.checkDestination()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = b.prepareDestination()
+	b.cache = nil
+	if b.cacheDir != "" {
+		b.cache = loadCache(b.cacheDir, b.destination, b.log)
+		defer func() { b.cache = nil }()
+	}
+
+	result, err := b.run(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		b.log.Warn("Build aborted before writing files", "error", err)
+		return nil, err
+	}
+
+	if b.openSink != nil {
+		b.log.Info("Writing files to output sink", "count", len(result.Files))
+		writeStart := time.Now()
+		err = b.writeSink(ctx, result.Files)
+		if err != nil {
+			b.log.Error("Failed writing files to output sink", "duration", time.Since(writeStart), "error", err)
+			return nil, err
+		}
+		if b.cache != nil {
+			if err := b.cache.save(); err != nil {
+				b.log.Error("Failed to update build cache", "cache_dir", b.cacheDir, "error", err)
+				return nil, err
+			}
+		}
+		if err := b.finishReport(result.Report, startTime); err != nil {
+			return nil, err
+		}
+		b.log.Info("Build successful",
+			"files_written", len(result.Files),
+			"total_duration", time.Since(startTime),
+		)
+		return result.Report, nil
+	}
+
+	staging, err := b.prepareStaging()
+	if err != nil {
+		return nil, err
+	}
+	swapped := false
+	defer func() {
+		if swapped {
+			return
+		}
+		b.log.Debug("Removing staging directory", "staging", staging)
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			b.log.Error("Failed to remove staging directory", "staging", staging, "error", rmErr)
+		}
+	}()
+
+	b.log.Info("Writing files to staging directory", "staging", staging, "count", len(result.Files))
+	writeStart := time.Now()
+	err = b.writeFiles(ctx, staging, result.Files)
+	writeDuration := time.Since(writeStart)
+	if err != nil {
+		b.log.Error("Failed writing files to staging directory", "staging", staging, "duration", writeDuration, "error", err)
+		return nil, err
+	}
+
+	if b.reproducible {
+		err = normalizeDirTimes(staging, b.timestamp)
+		if err != nil {
+			b.log.Error("Failed to set directory modification times", "staging", staging, "error", err)
+			return nil, fmt.Errorf("failed to set directory modification times in %s: %w", staging, err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		b.log.Warn("Build aborted before replacing destination", "error", err)
+		return nil, err
+	}
+	err = b.swapDestination(staging)
+	if err != nil {
+		return nil, err
+	}
+	swapped = true
+
+	if b.cache != nil {
+		err = b.finishCache()
+		if err != nil {
+			b.log.Error("Failed to update build cache", "cache_dir", b.cacheDir, "error", err)
+			return nil, err
+		}
+	}
+
+	err = b.finishReport(result.Report, startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	buildDuration := time.Since(startTime)
+	b.log.Info("Build successful",
+		"destination", b.destination,
+		"files_written", len(result.Files),
+		"total_duration", buildDuration,
+	)
+
+	return result.Report, nil
+}
+
+// Run applies the transformers in the stack to the contents of
+// every file in the source directory like [Builder.BuildContext],
+// but returns the resulting files and store instead of writing them
+// to the destination. It does not require a destination, and never
+// touches it.
+func (b *Builder) Run(ctx context.Context) (*Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.log.Info("Run started")
+	err := b.checkSource()
+	if err != nil {
+		b.log.Error("Source check failed", "error", err)
+		return nil, err
+	}
+	result, err := b.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.report.Store(result.Report)
+	return result, nil
+}
+
+// run walks the source directory and applies the transformers,
+// starting from no files and a copy of the seed store.
+func (b *Builder) run(ctx context.Context) (result *Result, err error) {
+	b.files = nil
+	// The files belong to the result once the run is over.
+	defer func() { b.files = nil }()
+	store := maps.Clone(b.store)
+	if store == nil {
+		store = make(Store)
+	}
+	report := &BuildReport{Started: time.Now()}
+
+	teardown, err := b.setupPlugins(ctx)
+	defer func() {
+		if teardownErr := teardown(); teardownErr != nil {
+			b.log.Error("Transformer teardown failed", "error", teardownErr)
+			if err == nil {
+				result, err = nil, teardownErr
+			}
+		}
+	}()
+	if err != nil {
+		b.log.Error("Transformer setup failed", "error", err)
+		return nil, err
 	}
 
-	b.log.Info("Walking source directory", "source", b.source)
+	b.log.Info("Walking source directory", "mounts", b.mounts)
 	walkStart := time.Now()
-	err = filepath.WalkDir(b.source, b.srcWalker)
+	// Errors of single files, collected with Config.CollectErrors.
+	var collected []error
+	err = b.readSources(ctx)
 	walkDuration := time.Since(walkStart)
-	if err != nil {
-		b.log.Error("Failed during source walk", "source", b.source, "error", err)
-		return fmt.Errorf("error walking source directory %s: %w", b.source, err)
+	if fileErrs, ok := b.collectable(err); ok {
+		b.log.Warn("Failed to read some source files", "count", len(fileErrs))
+		for _, fileErr := range fileErrs {
+			collected = append(collected, fileErr)
+		}
+	} else if err != nil {
+		return nil, err
 	}
 	b.log.Info("Finished walking source directory", "files_found", len(b.files), "duration", walkDuration)
+	if b.reproducible {
+		sortFiles(b.files)
+	}
+	report.Inputs = filePaths(b.files)
 
 	b.log.Info("Applying transformers", "count", len(b.transformers))
 	transformStart := time.Now()
 	for i, transformer := range b.transformers {
+		if err := ctx.Err(); err != nil {
+			b.log.Warn("Build aborted before transformer", "index", i, "name", transformer.name, "error", err)
+			return nil, err
+		}
 		tfStartTime := time.Now()
-		b.log.Debug("Executing transformer", "index", i)
-		err := transformer(&b.files, &b.store)
+		b.log.Debug("Executing transformer", "index", i, "name", transformer.name)
+		pathsBefore := filePaths(b.files)
+		setBaseURL(b.files, b.baseURL)
+		resolveStore(b.files, store)
+		err := transformer.transform(ctx, &b.files, &store)
 		tfDuration := time.Since(tfStartTime)
-		if err != nil {
-			b.log.Error("Transformer failed", "index", i, "duration", tfDuration, "error", err)
-			return fmt.Errorf("transformer at index %d failed: %w", i, err)
+		if fileErrs, ok := b.collectable(err); ok {
+			b.log.Warn("Transformer failed for some files", "index", i, "name", transformer.name, "count", len(fileErrs), "error", err)
+			removeFailedFiles(&b.files, fileErrs)
+			for _, fileErr := range fileErrs {
+				collected = append(collected, fmt.Errorf("%s failed: %w", transformer, fileErr))
+			}
+		} else if err != nil {
+			b.log.Error("Transformer failed", "index", i, "name", transformer.name, "duration", tfDuration, "error", err)
+			return nil, errors.Join(append(collected, fmt.Errorf("%s failed: %w", transformer, err))...)
 		}
-		b.log.Debug("Finished transformer", "index", i, "duration", tfDuration)
+		b.log.Debug("Finished transformer", "index", i, "name", transformer.name, "duration", tfDuration)
+
+		added, removed := diffPaths(pathsBefore, filePaths(b.files))
+		report.Transformers = append(report.Transformers, TransformerReport{
+			Index:    i,
+			Name:     transformer.name,
+			Duration: tfDuration,
+			Added:    added,
+			Removed:  removed,
+		})
 	}
 	transformDuration := time.Since(transformStart)
 	b.log.Info("Finished applying all transformers", "count", len(b.transformers), "duration", transformDuration)
 
-	b.log.Info("Writing files to destination", "destination", b.destination, "count", len(b.files))
-	writeStart := time.Now()
-	err = b.writeFiles()
-	writeDuration := time.Since(writeStart)
+	if len(collected) > 0 {
+		b.log.Error("Build failed for some files", "count", len(collected))
+		return nil, errors.Join(collected...)
+	}
+
+	b.files, err = b.resolveCollisions(b.files)
 	if err != nil {
-		b.log.Error("Failed writing files to destination", "destination", b.destination, "duration", writeDuration, "error", err)
-		return err
+		b.log.Error("Files collide at output paths", "error", err)
+		return nil, err
 	}
-	buildDuration := time.Since(startTime)
-	b.log.Info("Build successful",
-		"destination", b.destination,
-		"files_written", len(b.files),
-		"total_duration", buildDuration,
+
+	setBaseURL(b.files, b.baseURL)
+	resolveStore(b.files, store)
+	if b.reproducible {
+		b.normalizeFiles(b.files)
+	}
+	report.Outputs, err = outputReports(b.files)
+	if err != nil {
+		b.log.Error("Failed to describe outputs", "error", err)
+		return nil, err
+	}
+	report.Duration = time.Since(report.Started)
+	return &Result{Files: b.files, Store: store, Report: report}, nil
+}
+
+// setBaseURL sets the base URL of files, including
+// those transformers created since it was last set.
+func setBaseURL(files []File, baseURL string) {
+	for i := range files {
+		files[i].baseURL = baseURL
+	}
+}
+
+func (b *Builder) transformFiles(ctx context.Context, index int, transformer FileTransformer, filesPtr *[]File, store *Store) error {
+	files := *filesPtr
+	workers := min(b.concurrency, len(files))
+	b.log.Debug("Transforming files", "count", len(files), "workers", workers)
+
+	// Files are handed out in order, and no new files are handed
+	// out after a failure. Every file before a failing one is
+	// therefore transformed, so the first error by index is the
+	// same one a serial run would have returned. When collecting
+	// errors, only cancellation stops handing out files.
+	var (
+		next   atomic.Int64
+		failed atomic.Bool
+		errs   = make([]error, len(files))
+		wg     sync.WaitGroup
 	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= len(files) {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					failed.Store(true)
+					return
+				}
+				path := files[i].Path
+				if err := b.transformFile(index, transformer, &files[i], store); err != nil {
+					errs[i] = &FileError{Path: path, Err: err}
+					if !b.collectErrors {
+						failed.Store(true)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if b.collectErrors && !failed.Load() {
+		var fileErrs []error
+		remaining := files[:0]
+		for i, err := range errs {
+			if err != nil {
+				fileErrs = append(fileErrs, err)
+			} else {
+				remaining = append(remaining, files[i])
+			}
+		}
+		*filesPtr = remaining
+		return errors.Join(fileErrs...)
+	}
 
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (b *Builder) writeFiles() error {
-	b.log.Debug("Starting file writing process", "count", len(b.files))
-	for i, file := range b.files {
-		writePath := filepath.Join(b.destination, file.Path)
-		writeDir := filepath.Dir(writePath)
+// collectable returns the file errors err consists of,
+// if they are collected instead of failing the build.
+func (b *Builder) collectable(err error) ([]*FileError, bool) {
+	if !b.collectErrors {
+		return nil, false
+	}
+	return fileErrors(err)
+}
 
-		b.log.Debug("Preparing to write file", "index", i, "target_path", writePath)
+// transformFile runs transformer on file, or replays
+// its result from the cache if it ran before.
+func (b *Builder) transformFile(index int, transformer FileTransformer, file *File, store *Store) error {
+	if b.cache == nil {
+		if err := transformer(file, store); err != nil {
+			return err
+		}
+		return file.err
+	}
 
-		err := os.MkdirAll(writeDir, 0755)
-		if err != nil {
-			b.log.Error("Failed to create directory for file", "directory", writeDir, "file_path", writePath, "error", err)
-			return fmt.Errorf("failed to create directory %s: %w", writeDir, err)
+	hash, err := file.contentHash()
+	if err != nil {
+		return err
+	}
+	key := resultKey(index, file, hash)
+	if result, content, ok := b.cache.lookup(key); ok {
+		b.log.Debug("Using cached transformer result", "index", index, "path", file.Path)
+		file.Path = result.Path
+		if !result.Unchanged {
+			file.content = nil
+			file.lazy = content
+			file.updateInfoSize()
+		}
+		return nil
+	}
+
+	if err := transformer(file, store); err != nil {
+		return err
+	}
+	if file.err != nil {
+		return file.err
+	}
+	return b.cache.store(key, file, hash)
+}
+
+// writeFiles writes files into dir. With a cache, files that did not
+// change since the previous build are linked from the destination
+// instead of being written again.
+func (b *Builder) writeFiles(ctx context.Context, dir string, files []File) error {
+	b.log.Debug("Starting file writing process", "count", len(files))
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		writePath := filepath.Join(dir, file.Path)
+
+		b.log.Debug("Preparing to write file", "index", i, "target_path", writePath)
 
-		var perm fs.FileMode = 0644
-		if file.FileInfo != nil {
-			perm = file.FileInfo.Mode().Perm()
+		if b.cache != nil {
+			hash, err := file.contentHash()
+			if err != nil {
+				return err
+			}
+			b.cache.recordOutput(file.Path, hash)
+			prevPath := filepath.Join(b.destination, file.Path)
+			if b.cache.unchanged(file.Path, hash, prevPath) {
+				err := linkFile(prevPath, writePath)
+				if err == nil {
+					b.log.Debug("Linked unchanged file", "index", i, "path", writePath)
+					continue
+				}
+				b.log.Debug("Failed to link unchanged file, writing it instead", "index", i, "path", writePath, "error", err)
+			}
 		}
 
-		err = os.WriteFile(writePath, file.content, perm)
+		err := dirSink{dir: dir, exact: b.reproducible}.Write(&file)
 		if err != nil {
-			b.log.Error("Failed to write file content", "file_path", writePath, "error", err)
-			return fmt.Errorf("failed to write file %s: %w", writePath, err)
+			b.log.Error("Failed to write file", "file_path", writePath, "error", err)
+			return err
 		}
-		b.log.Debug("Successfully wrote file", "index", i, "path", writePath, "size", len(file.content))
+		b.log.Debug("Successfully wrote file", "index", i, "path", writePath, "size", file.size())
 	}
 	b.log.Debug("Finished file writing process")
 	return nil
 }
 
+// writeSink opens an output sink, writes files to it and closes it.
+func (b *Builder) writeSink(ctx context.Context, files []File) error {
+	sink, err := b.openSink()
+	if err != nil {
+		return fmt.Errorf("failed to open output sink: %w", err)
+	}
+	b.log.Debug("Starting sink writing process", "count", len(files), "sink", fmt.Sprintf("%T", sink))
+	for i := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := sink.Write(&files[i]); err != nil {
+			b.log.Error("Failed to write file to sink", "file_path", files[i].Path, "error", err)
+			return err
+		}
+		b.log.Debug("Successfully wrote file to sink", "index", i, "path", files[i].Path, "size", files[i].size())
+	}
+	if err := sink.Close(); err != nil {
+		return fmt.Errorf("failed to close output sink: %w", err)
+	}
+	b.log.Debug("Finished sink writing process")
+	return nil
+}
+
 func (b *Builder) checkSourceAndDestination() error {
 	b.log.Debug("Checking source and destination paths")
-	if b.destination == "" {
+	if b.destination == "" && b.openSink == nil {
 		err := fmt.Errorf("destination directory not defined")
 		b.log.Error("Validation failed", "reason", err)
 		return err
 	}
-	if b.source == "" {
+	return b.checkSource()
+}
+
+func (b *Builder) checkSource() error {
+	if len(b.mounts) == 0 {
 		err := fmt.Errorf("source directory not defined")
 		b.log.Error("Validation failed", "reason", err)
 		return err
 	}
+	for _, mount := range b.mounts {
+		if err := b.checkMount(mount); err != nil {
+			return err
+		}
+	}
+	b.log.Debug("Source path validated successfully")
+	return nil
+}
 
-	b.log.Debug("Checking source path existence and type", "source", b.source)
-	sourceInfo, err := os.Stat(b.source)
+func (b *Builder) checkMount(mount Mount) error {
+	if mount.Dir == "" {
+		b.log.Debug("Checking source file system", "prefix", mount.Prefix)
+		sourceInfo, err := fs.Stat(mount.fsys, ".")
+		if err != nil {
+			err = fmt.Errorf("failed to stat root of source file system: %w", err)
+			b.log.Error("Validation failed", "reason", "stat error", "error", err)
+			return err
+		}
+		if !sourceInfo.IsDir() {
+			err = fmt.Errorf("root of source file system is not a directory")
+			b.log.Error("Validation failed", "reason", err)
+			return err
+		}
+		return nil
+	}
+
+	b.log.Debug("Checking source path existence and type", "source", mount.Dir)
+	sourceInfo, err := os.Stat(mount.Dir)
 	if os.IsNotExist(err) {
-		err = fmt.Errorf("source directory '%s' does not exist", b.source)
-		b.log.Error("Validation failed", "reason", err, "source", b.source)
+		err = fmt.Errorf("source directory '%s' does not exist", mount.Dir)
+		b.log.Error("Validation failed", "reason", err, "source", mount.Dir)
 		return err
 	} else if err != nil {
-		err = fmt.Errorf("failed to stat source directory '%s': %w", b.source, err)
-		b.log.Error("Validation failed", "reason", "stat error", "source", b.source, "error", err)
+		err = fmt.Errorf("failed to stat source directory '%s': %w", mount.Dir, err)
+		b.log.Error("Validation failed", "reason", "stat error", "source", mount.Dir, "error", err)
 		return err
 	}
 	if !sourceInfo.IsDir() {
-		err = fmt.Errorf("source path '%s' is not a directory", b.source)
-		b.log.Error("Validation failed", "reason", "source not a directory", "source", b.source)
+		err = fmt.Errorf("source path '%s' is not a directory", mount.Dir)
+		b.log.Error("Validation failed", "reason", "source not a directory", "source", mount.Dir)
 		return err
 	}
-	b.log.Debug("Source and destination paths validated successfully")
 	return nil
 }
 
-func (b *Builder) prepareDestination() error {
-	b.log.Debug("Preparing destination directory", "destination", b.destination)
+// checkDestination returns ErrDestinationExists early, before any
+// work is done, if the destination exists and may not be overwritten.
+func (b *Builder) checkDestination() error {
 	_, err := os.Stat(b.destination)
+	if err == nil && !b.autoConfirm {
+		b.log.Warn("Destination exists but overwrite not permitted", "destination", b.destination)
+		return fmt.Errorf("%w: %s", ErrDestinationExists, b.destination)
+	}
+	return nil
+}
 
+// prepareStaging creates the directory the files are written to
+// before they replace the destination. It is created next to the
+// destination, so it can be renamed into place.
+func (b *Builder) prepareStaging() (string, error) {
+	parent := filepath.Dir(b.destination)
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		b.log.Error("Failed to create parent of destination directory", "parent", parent, "error", err)
+		return "", fmt.Errorf("failed to create parent of destination directory %s: %w", parent, err)
+	}
+
+	staging, err := os.MkdirTemp(parent, stagingPrefix(b.destination))
 	if err == nil {
-		b.log.Debug("Destination directory exists", "destination", b.destination)
-		if !b.autoConfirm {
-			b.log.Warn("Destination exists but overwrite not permitted", "destination", b.destination)
-			return fmt.Errorf("%w: %s", ErrDestinationExists, b.destination)
-		}
+		// Temporary directories are only accessible by their owner.
+		err = os.Chmod(staging, 0755)
+	}
+	if err != nil {
+		b.log.Error("Failed to create staging directory", "parent", parent, "error", err)
+		return "", fmt.Errorf("failed to create staging directory in %s: %w", parent, err)
+	}
+	b.log.Debug("Staging directory prepared", "staging", staging)
+	return staging, nil
+}
 
-		b.log.Info("Removing existing destination directory", "destination", b.destination)
-		removeStart := time.Now()
-		err = os.RemoveAll(b.destination)
-		removeDuration := time.Since(removeStart)
-		if err != nil {
-			b.log.Error("Failed to remove existing destination directory", "destination", b.destination, "duration", removeDuration, "error", err)
-			return fmt.Errorf("failed to remove existing destination directory %s: %w", b.destination, err)
-		}
-		b.log.Debug("Existing destination directory removed", "destination", b.destination, "duration", removeDuration)
+// stagingPrefix returns the prefix of the name of staging
+// directories of destination.
+func stagingPrefix(destination string) string {
+	return "." + filepath.Base(destination) + ".tmp-"
+}
+
+// isStaging reports whether path is in a
+// staging directory of destination.
+func isStaging(path string, destination string) bool {
+	if destination == "" {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Dir(destination), path)
+	if err != nil {
+		return false
+	}
+	first, _, _ := strings.Cut(rel, string(os.PathSeparator))
+	return strings.HasPrefix(first, stagingPrefix(destination))
+}
 
-	} else if !os.IsNotExist(err) {
+// swapDestination replaces the destination with staging. The
+// existing destination is moved aside first, and only removed
+// once staging took its place. If the destination is a symbolic
+// link or can not be moved, its contents are replaced instead.
+func (b *Builder) swapDestination(staging string) error {
+	old := staging + ".old"
+	info, err := os.Lstat(b.destination)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
 		b.log.Error("Failed to check destination directory status", "destination", b.destination, "error", err)
 		return fmt.Errorf("failed to check destination directory %s: %w", b.destination, err)
-	} else {
-		b.log.Debug("Destination directory does not exist, will create.", "destination", b.destination)
 	}
 
-	b.log.Debug("Creating destination directory", "destination", b.destination)
-	createStart := time.Now()
-	err = os.MkdirAll(b.destination, 0755)
-	createDuration := time.Since(createStart)
+	if exists && info.Mode()&fs.ModeSymlink != 0 {
+		b.log.Warn("Destination is a symbolic link, replacing its contents in place", "destination", b.destination)
+		return b.replaceContents(staging)
+	}
+	if exists {
+		b.log.Debug("Moving existing destination aside", "destination", b.destination, "old", old)
+		if err := os.Rename(b.destination, old); err != nil {
+			b.log.Warn("Failed to move existing destination aside, replacing its contents in place", "destination", b.destination, "error", err)
+			return b.replaceContents(staging)
+		}
+	}
+
+	if err := os.Rename(staging, b.destination); err != nil {
+		b.log.Error("Failed to move staging directory into place", "staging", staging, "destination", b.destination, "error", err)
+		if exists {
+			if restoreErr := os.Rename(old, b.destination); restoreErr != nil {
+				b.log.Error("Failed to restore previous destination", "old", old, "destination", b.destination, "error", restoreErr)
+			}
+		}
+		return fmt.Errorf("failed to replace destination directory %s: %w", b.destination, err)
+	}
+	b.log.Info("Destination directory replaced", "destination", b.destination)
+
+	if exists {
+		b.log.Debug("Removing previous destination", "old", old)
+		if err := os.RemoveAll(old); err != nil {
+			b.log.Error("Failed to remove previous destination", "old", old, "error", err)
+		}
+	}
+	return nil
+}
+
+// replaceContents replaces the contents of the destination with those
+// of staging, keeping the destination itself, and removes staging.
+// Entries are moved if possible, and copied otherwise, such as when
+// the destination is on another file system.
+func (b *Builder) replaceContents(staging string) error {
+	entries, err := os.ReadDir(b.destination)
+	if err != nil {
+		return fmt.Errorf("failed to read destination directory %s: %w", b.destination, err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(b.destination, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clear destination directory %s: %w", b.destination, err)
+		}
+	}
+
+	entries, err = os.ReadDir(staging)
 	if err != nil {
-		b.log.Error("Failed to create destination directory", "destination", b.destination, "duration", createDuration, "error", err)
-		return fmt.Errorf("failed to create destination directory %s: %w", b.destination, err)
+		return fmt.Errorf("failed to read staging directory %s: %w", staging, err)
+	}
+	for _, entry := range entries {
+		src := filepath.Join(staging, entry.Name())
+		dst := filepath.Join(b.destination, entry.Name())
+		if os.Rename(src, dst) == nil {
+			continue
+		}
+		if err := copyTree(src, dst); err != nil {
+			return fmt.Errorf("failed to copy %s into destination directory: %w", entry.Name(), err)
+		}
+	}
+	if b.reproducible {
+		if err := normalizeDirTimes(b.destination, b.timestamp); err != nil {
+			return fmt.Errorf("failed to set directory modification times in %s: %w", b.destination, err)
+		}
+	}
+	b.log.Info("Destination contents replaced", "destination", b.destination)
+
+	if err := os.RemoveAll(staging); err != nil {
+		b.log.Error("Failed to remove staging directory", "staging", staging, "error", err)
 	}
-	b.log.Info("Destination directory prepared", "destination", b.destination, "duration", createDuration)
 	return nil
 }
+
+// copyTree copies the file or directory at src to dst, keeping
+// the mode and modification time of files and directories.
+func copyTree(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			err = os.MkdirAll(target, info.Mode().Perm())
+		} else {
+			err = copyFile(path, target, info.Mode().Perm())
+		}
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+// copyFile copies the file at src to dst with the given mode.
+func copyFile(src string, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// linkFile creates a hard link to oldPath at newPath,
+// creating the directory of newPath if needed.
+func linkFile(oldPath string, newPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	return os.Link(oldPath, newPath)
+}
+
+// finishCache saves the build cache. If that fails, the cache is
+// cleared, since it no longer describes the destination.
+func (b *Builder) finishCache() error {
+	added, modified, removed := b.cache.changedSources()
+	b.log.Info("Sources changed since last build", "added", added, "modified", modified, "removed", removed)
+
+	err := b.cache.save()
+	if err != nil {
+		b.cache.clear()
+	}
+	return err
+}
+
+// finishReport completes the report of a successful build
+// started at startTime, and writes it to the report file.
+func (b *Builder) finishReport(report *BuildReport, startTime time.Time) error {
+	report.Started = startTime
+	report.Duration = time.Since(startTime)
+	b.report.Store(report)
+
+	if b.reportFile == "" {
+		return nil
+	}
+	b.log.Debug("Writing build report", "path", b.reportFile)
+	err := writeReport(b.reportFile, report)
+	if err != nil {
+		b.log.Error("Failed to write build report", "path", b.reportFile, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,560 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Helper to create a source tree in a temporary working directory
+func makeSource(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(dir, "src", path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuild(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"index.html":     "index",
+		"blog/post.html": "---\ntitle: Post\n---\npost",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Destination("build")
+
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "build", "blog", "post.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "post" {
+		t.Errorf("got %q, want %q", got, "post")
+	}
+}
+
+func TestBuildContextCancelled(t *testing.T) {
+	dir := makeSource(t, map[string]string{"index.html": "index"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Destination("build")
+	b.UseContext(func(ctx context.Context, files *[]File, store *Store) error {
+		cancel()
+		return nil
+	})
+	b.Use(func(files *[]File, store *Store) error {
+		t.Error("transformer ran after cancellation")
+		return nil
+	})
+
+	err := b.BuildContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "build")); !os.IsNotExist(err) {
+		t.Errorf("destination should not exist after an aborted build, stat error: %v", err)
+	}
+}
+
+func TestUseFile(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"a.txt": "a",
+		"b.txt": "b",
+		"c.txt": "c",
+		"d.txt": "d",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir, Concurrency: 4})
+	b.Source("src")
+	b.Destination("build")
+	b.UseFile(func(file *File, store *Store) error {
+		file.SetContent(append(file.Content(), '!'))
+		return nil
+	})
+	var paths []string
+	b.Use(func(files *[]File, store *Store) error {
+		for _, file := range *files {
+			paths = append(paths, file.Path)
+		}
+		return nil
+	})
+
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"a.txt", "b.txt", "c.txt", "d.txt"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("file order changed: got %v, want %v", paths, want)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "build", "c.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "c!" {
+		t.Errorf("got %q, want %q", got, "c!")
+	}
+}
+
+func TestUseFileFirstError(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"a.txt": "a",
+		"b.txt": "b",
+		"c.txt": "c",
+		"d.txt": "d",
+	})
+	errFailed := errors.New("failed")
+
+	for range 20 {
+		b := NewBuilder(Config{WorkingDir: dir, Concurrency: 4})
+		b.Source("src")
+		b.Destination("build")
+		b.UseFile(func(file *File, store *Store) error {
+			if file.Path != "a.txt" {
+				return errFailed
+			}
+			return nil
+		})
+
+		err := b.Build()
+		if !errors.Is(err, errFailed) {
+			t.Fatalf("expected errFailed, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "b.txt") {
+			t.Fatalf("expected error for first failing file b.txt, got: %v", err)
+		}
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"a.txt":      "a",
+		"b.txt":      "b",
+		"blog/c.txt": "c",
+	})
+
+	var calls []string
+	build := func() {
+		t.Helper()
+		calls = nil
+		b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true, CacheDir: ".cache", Concurrency: 1})
+		b.Source("src")
+		b.Destination("build")
+		b.UseFile(func(file *File, store *Store) error {
+			calls = append(calls, file.Path)
+			file.SetContent(append(file.Content(), '!'))
+			return nil
+		})
+		if err := b.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	build()
+	if len(calls) != 3 {
+		t.Fatalf("expected transformer to run on 3 files, ran on %v", calls)
+	}
+
+	build()
+	if len(calls) != 0 {
+		t.Fatalf("expected transformer not to run on unchanged files, ran on %v", calls)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "build", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "a!" {
+		t.Errorf("got %q, want %q", got, "a!")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "src", "blog", "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	build()
+	if !slices.Equal(calls, []string{"a.txt"}) {
+		t.Fatalf("expected transformer to only run on a.txt, ran on %v", calls)
+	}
+	got, err = os.ReadFile(filepath.Join(dir, "build", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "A!" {
+		t.Errorf("got %q, want %q", got, "A!")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "build", "blog")); !os.IsNotExist(err) {
+		t.Errorf("output of deleted source should be removed, stat error: %v", err)
+	}
+}
+
+func TestBuildCacheUnchangedContent(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"a.txt":   "a",
+		"img.bin": strings.Repeat("\x00", 1<<16),
+	})
+
+	var calls []string
+	build := func() {
+		t.Helper()
+		calls = nil
+		b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true, CacheDir: ".cache", Concurrency: 1})
+		b.Source("src")
+		b.Destination("build")
+		b.UseFile(func(file *File, store *Store) error {
+			calls = append(calls, file.Path)
+			if filepath.Ext(file.Path) == ".txt" {
+				file.SetContent(append(file.Content(), '!'))
+			}
+			return nil
+		})
+		if err := b.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	build()
+	var objects []string
+	err := filepath.WalkDir(filepath.Join(dir, ".cache", "objects"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			objects = append(objects, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected only the changed file to be stored, got %v", objects)
+	}
+
+	build()
+	if len(calls) != 0 {
+		t.Fatalf("expected transformer not to run on unchanged files, ran on %v", calls)
+	}
+	info, err := os.Stat(filepath.Join(dir, "build", "img.bin"))
+	if err != nil || info.Size() != 1<<16 {
+		t.Errorf("expected unchanged file to be written, got %v, %v", info, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := makeSource(t, map[string]string{"index.html": "one"})
+
+	b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true, WatchInterval: 10 * time.Millisecond})
+	b.Source("src")
+	b.Destination("build")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.Watch(ctx) }()
+
+	waitForContent := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			got, _ := os.ReadFile(filepath.Join(dir, "build", "index.html"))
+			if string(got) == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("destination never contained %q", want)
+	}
+
+	waitForContent("one")
+	if err := os.WriteFile(filepath.Join(dir, "src", "index.html"), []byte("two!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent("two!")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWatchRequiresOverwrite(t *testing.T) {
+	b := NewBuilder()
+	b.Source("src")
+	err := b.Watch(context.Background())
+	if !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("expected ErrDestinationExists, got: %v", err)
+	}
+}
+
+func TestBuildFailureKeepsDestination(t *testing.T) {
+	dir := makeSource(t, map[string]string{"index.html": "new"})
+	dest := filepath.Join(dir, "build")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "index.html"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	errFailed := errors.New("failed")
+	b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true})
+	b.Source("src")
+	b.Destination("build")
+	b.Use(func(files *[]File, store *Store) error {
+		return errFailed
+	})
+	if err := b.Build(); !errors.Is(err, errFailed) {
+		t.Fatalf("expected transformer error, got %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dest, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "old" {
+		t.Errorf("expected destination to be untouched, got %q", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".build") {
+			t.Errorf("staging directory %s was left behind", entry.Name())
+		}
+	}
+}
+
+func TestBuildReplacesDestination(t *testing.T) {
+	dir := makeSource(t, map[string]string{"a.txt": "a", "b.txt": "b"})
+	dest := filepath.Join(dir, "build")
+
+	build := func() {
+		t.Helper()
+		b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true, CacheDir: ".cache"})
+		b.Source("src")
+		b.Destination("build")
+		if err := b.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	build()
+	before, err := os.Stat(filepath.Join(dest, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "stray.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	build()
+	after, err := os.Stat(filepath.Join(dest, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("expected unchanged output to be linked from the previous build")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "stray.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected destination to be replaced, stat error: %v", err)
+	}
+}
+
+func TestBuildKeepsSymlinkedDestination(t *testing.T) {
+	dir := makeSource(t, map[string]string{"a.txt": "a", "blog/b.txt": "b"})
+	target := filepath.Join(dir, "releases", "current")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "stray.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "build")
+	if err := os.Symlink(target, dest); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+
+	b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true})
+	b.Source("src")
+	b.Destination("build")
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Lstat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatal("expected destination to still be a symbolic link")
+	}
+	got, err := os.ReadFile(filepath.Join(target, "blog", "b.txt"))
+	if err != nil || string(got) != "b" {
+		t.Errorf("expected output in link target, got %q, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "stray.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected contents of link target to be replaced, stat error: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), stagingPrefix(dest)) {
+			t.Errorf("expected staging directory to be removed, found %s", entry.Name())
+		}
+	}
+}
+
+func TestDryRunSymlinkedDestination(t *testing.T) {
+	dir := makeSource(t, map[string]string{"a.txt": "a", "b.txt": "new"})
+	target := filepath.Join(dir, "releases", "current")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.txt": "a", "b.txt": "old", "c.txt": "c"} {
+		if err := os.WriteFile(filepath.Join(target, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "build")); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Destination("build")
+	changes, err := b.DryRun(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &Changes{
+		Modified:  []string{"b.txt"},
+		Removed:   []string{"c.txt"},
+		Unchanged: []string{"a.txt"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+}
+
+func TestBuildRepeatedly(t *testing.T) {
+	dir := makeSource(t, map[string]string{"a.html": "a", "b.html": "b"})
+	b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true, Store: Store{"site": "medusa"}})
+	b.Source("src")
+	b.Destination("build")
+	b.Use(func(files *[]File, store *Store) error {
+		if (*store)["ran"] != nil {
+			return errors.New("store leaked from previous build")
+		}
+		if (*store)["site"] != "medusa" {
+			return fmt.Errorf("store not seeded: %v", *store)
+		}
+		(*store)["ran"] = true
+		return nil
+	})
+
+	for i := range 3 {
+		if err := b.Build(); err != nil {
+			t.Fatalf("build %d: %v", i, err)
+		}
+		report := b.Report()
+		if len(report.Outputs) != 2 {
+			t.Errorf("build %d: expected 2 outputs, got %d", i, len(report.Outputs))
+		}
+	}
+}
+
+func TestConcurrentBuilds(t *testing.T) {
+	dir := makeSource(t, map[string]string{"a.html": "a", "b.html": "b"})
+	b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true})
+	b.Source("src")
+	b.Destination("build")
+	b.Use(func(files *[]File, store *Store) error {
+		(*store)["count"] = len(*files)
+		*files = append(*files, File{Path: "extra.html"})
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				errs[i] = b.Build()
+				return
+			}
+			result, err := b.Run(context.Background())
+			if err == nil && (len(result.Files) != 3 || result.Store["count"] != 2) {
+				err = fmt.Errorf("got %d files and store %v", len(result.Files), result.Store)
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("build %d: %v", i, err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "build"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("expected 3 files in destination, got %d", len(entries))
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	dir := makeSource(t, map[string]string{"a.txt": "a", "blog/b.sh": "b"})
+	src := filepath.Join(dir, "src")
+	if err := os.Chmod(filepath.Join(src, "blog", "b.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "copy")
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "blog", "b.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected mode 0755, got %v", info.Mode().Perm())
+	}
+	got, err := os.ReadFile(filepath.Join(dst, "a.txt"))
+	if err != nil || string(got) != "a" {
+		t.Errorf("expected copied content, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,188 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrMountConflict indicates that several mounts contain a file at
+// the same path, and Config.MountPolicy is MountError.
+var ErrMountConflict = errors.New("file exists in more than one mount")
+
+// Decides what happens when several mounts
+// contain a file at the same path.
+type MountPolicy int
+
+const (
+	// The file of the mount added last is used, and the files
+	// of earlier mounts at the same path are left out of the build.
+	MountOverride MountPolicy = iota
+
+	// The build fails with ErrMountConflict.
+	MountError
+)
+
+// A directory or file system overlaid onto the source tree, added
+// with [Builder.Source], [Builder.SourceFS], [Builder.Mount] or
+// [Builder.MountFS].
+type Mount struct {
+	// The directory of the mount, joined with WorkingDir.
+	// Empty if a file system was mounted.
+	Dir string
+
+	// The slash separated path in the source tree at
+	// which the mount is overlaid. Empty for the root.
+	Prefix string
+
+	fsys fs.FS
+}
+
+// Describes the mount in logs and errors.
+func (m Mount) String() string {
+	name := m.Dir
+	if name == "" {
+		name = "file system"
+	}
+	if m.Prefix == "" {
+		return name
+	}
+	return fmt.Sprintf("%s at %s", name, m.Prefix)
+}
+
+// treePath returns the slash separated path in the source
+// tree of the file at name in the mount.
+func (m Mount) treePath(name string) string {
+	return path.Join(m.Prefix, name)
+}
+
+// sourcePath returns the path of the file at name on disk,
+// or its path in the source tree if the mount is not a directory.
+func (m Mount) sourcePath(name string) string {
+	if m.Dir == "" {
+		return m.treePath(name)
+	}
+	return filepath.Join(m.Dir, filepath.FromSlash(name))
+}
+
+// Overlays dir, relative to WorkingDir as specified in [Config], onto
+// the source at prefix, which is a slash separated path in the source
+// tree. An empty prefix mounts dir at the root.
+//
+// Mounts are read in the order they were added. If several mounts
+// contain a file at the same path, Config.MountPolicy decides whether
+// the file of the mount added last wins, or the build fails.
+func (b *Builder) Mount(dir string, prefix string) {
+	absDir := filepath.Join(b.workingDir, dir)
+	b.log.Debug("Mounting source directory", "raw", dir, "absolute", absDir, "prefix", prefix)
+	b.mounts = append(b.mounts, Mount{
+		Dir:    absDir,
+		Prefix: cleanPrefix(prefix),
+		fsys:   os.DirFS(absDir),
+	})
+}
+
+// Overlays a file system, such as an [embed.FS], onto the source
+// at prefix. It is otherwise the same as [Builder.Mount].
+func (b *Builder) MountFS(fsys fs.FS, prefix string) {
+	b.log.Debug("Mounting source file system", "prefix", prefix)
+	b.mounts = append(b.mounts, Mount{
+		Prefix: cleanPrefix(prefix),
+		fsys:   fsys,
+	})
+}
+
+// cleanPrefix turns prefix into a slash separated
+// path without a leading slash, which can not refer
+// to anything outside of the source tree.
+func cleanPrefix(prefix string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(prefix)), "/")
+}
+
+// readSources walks every mount in order and adds its files to the
+// builder's files. With Config.CollectErrors, files that fail to be
+// read are skipped, and their errors are returned joined at the end.
+func (b *Builder) readSources(ctx context.Context) error {
+	for _, pattern := range b.frontmatter {
+		if _, err := CompileGlob(pattern); err != nil {
+			b.log.Error("Invalid frontmatter pattern", "pattern", pattern, "error", err)
+			return fmt.Errorf("invalid frontmatter pattern: %w", err)
+		}
+	}
+
+	// Maps paths in the source tree to the index of their file.
+	seen := make(map[string]int)
+	var fileErrs []error
+	for _, mount := range b.mounts {
+		ignore, err := loadIgnoreRules(mount.fsys, b.ignore, mount.Prefix)
+		if err != nil {
+			b.log.Error("Failed to load ignore rules", "mount", mount, "error", err)
+			return err
+		}
+
+		err = fs.WalkDir(mount.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				return err
+			}
+			if name != "." && (name == ignoreFileName || ignore.ignored(mount.treePath(name), d.IsDir())) {
+				b.log.Debug("Ignoring path", "path", name, "mount", mount)
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			file, err := b.readSourceFile(mount, name, d)
+			if err != nil && b.collectErrors {
+				fileErrs = append(fileErrs, &FileError{Path: filepath.FromSlash(mount.treePath(name)), Err: err})
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return b.addSourceFile(file, seen)
+		})
+		if err != nil {
+			b.log.Error("Failed during source walk", "mount", mount, "error", err)
+			return fmt.Errorf("error walking source directory %s: %w", mount, err)
+		}
+	}
+	return errors.Join(fileErrs...)
+}
+
+// addSourceFile adds file to the builder's files, replacing
+// a file of an earlier mount at the same path.
+func (b *Builder) addSourceFile(file File, seen map[string]int) error {
+	if i, ok := seen[file.Path]; ok {
+		if b.mountPolicy == MountError {
+			return fmt.Errorf("%w: %s in %s and %s", ErrMountConflict, file.Path, b.files[i].Mount, file.Mount)
+		}
+		b.log.Debug("Overriding file of earlier mount", "path", file.Path, "previous", b.files[i].Mount, "mount", file.Mount)
+		b.files[i] = file
+	} else {
+		seen[file.Path] = len(b.files)
+		b.files = append(b.files, file)
+	}
+
+	if b.cache != nil {
+		// The key of a transformer that does not exist
+		// doubles as the hash of the source file.
+		hash, err := file.contentHash()
+		if err != nil {
+			return err
+		}
+		b.cache.recordSource(file.Path, resultKey(-1, &file, hash))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMount(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"theme/index.html":      "theme index",
+		"theme/style.css":       "theme style",
+		"content/index.html":    "content index",
+		"content/post.html":     "post",
+		"content/.medusaignore": "*.draft\n",
+		"content/wip.draft":     "draft",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Mount("src/theme", "")
+	b.Mount("src/content", "")
+	b.MountFS(fstest.MapFS{"logo.svg": {Data: []byte("logo")}}, "/assets/")
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]struct {
+		content string
+		mount   Mount
+	}{
+		"index.html":                        {"content index", Mount{Dir: filepath.Join(dir, "src/content")}},
+		"style.css":                         {"theme style", Mount{Dir: filepath.Join(dir, "src/theme")}},
+		"post.html":                         {"post", Mount{Dir: filepath.Join(dir, "src/content")}},
+		filepath.Join("assets", "logo.svg"): {"logo", Mount{Prefix: "assets"}},
+	}
+	if len(result.Files) != len(want) {
+		t.Fatalf("expected %d files, got %d", len(want), len(result.Files))
+	}
+	for _, file := range result.Files {
+		w, ok := want[file.Path]
+		if !ok {
+			t.Errorf("unexpected file %s", file.Path)
+			continue
+		}
+		if string(file.Content()) != w.content {
+			t.Errorf("%s: expected content %q, got %q", file.Path, w.content, file.Content())
+		}
+		if file.Mount.Dir != w.mount.Dir || file.Mount.Prefix != w.mount.Prefix {
+			t.Errorf("%s: expected mount %s, got %s", file.Path, w.mount, file.Mount)
+		}
+	}
+}
+
+func TestMountError(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"theme/index.html":   "theme index",
+		"content/index.html": "content index",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir, MountPolicy: MountError})
+	b.Mount("src/theme", "")
+	b.Mount("src/content", "")
+
+	_, err := b.Run(context.Background())
+	if !errors.Is(err, ErrMountConflict) {
+		t.Fatalf("expected ErrMountConflict, got %v", err)
+	}
+}
+
+func TestSourceReplacesMounts(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"theme/style.css":    "style",
+		"content/index.html": "index",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Mount("src/theme", "")
+	b.Source("src/content")
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Path != "index.html" {
+		t.Errorf("expected only index.html, got %v", filePaths(result.Files))
+	}
+}
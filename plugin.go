@@ -0,0 +1,170 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// A Plugin is a step of the build, added with [Builder.UsePlugin].
+// [Transformer], [ContextTransformer] and [FileTransformer] are
+// plugins, and so is any type with a Transform method.
+//
+// A plugin may also implement [Namer] to be identified by name in
+// logs, errors and reports, and [Lifecycle] to acquire and release
+// resources once per build.
+type Plugin interface {
+	Transform(ctx context.Context, files *[]File, store *Store) error
+}
+
+// Implemented by plugins that have a name.
+type Namer interface {
+	Name() string
+}
+
+// Implemented by plugins that need to prepare before the
+// transformers are applied, and clean up afterwards.
+type Lifecycle interface {
+	// Setup is called before the source is read. If it fails,
+	// the build fails.
+	Setup(ctx context.Context) error
+
+	// Teardown is called after the last transformer, also when the
+	// build failed, for every plugin whose Setup succeeded. Plugins
+	// are torn down in the reverse order of their setup.
+	Teardown() error
+}
+
+func (t Transformer) Transform(ctx context.Context, files *[]File, store *Store) error {
+	return t(files, store)
+}
+
+func (t ContextTransformer) Transform(ctx context.Context, files *[]File, store *Store) error {
+	return t(ctx, files, store)
+}
+
+// Transform applies t to one file after another. The builder
+// does not call it, but applies t to several files at once.
+func (t FileTransformer) Transform(ctx context.Context, files *[]File, store *Store) error {
+	for i := range *files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t(&(*files)[i], store); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Implemented by plugins that wrap a [FileTransformer],
+// so the builder can still apply it to several files at once.
+type fileTransformerPlugin interface {
+	fileTransformer() (FileTransformer, bool)
+}
+
+// asFileTransformer returns the [FileTransformer] behind plugin, if any.
+func asFileTransformer(plugin Plugin) (FileTransformer, bool) {
+	switch p := plugin.(type) {
+	case FileTransformer:
+		return p, true
+	case fileTransformerPlugin:
+		return p.fileTransformer()
+	}
+	return nil, false
+}
+
+// pluginName returns the name of plugin, or "" if it has none.
+func pluginName(plugin Plugin) string {
+	if namer, ok := plugin.(Namer); ok {
+		return namer.Name()
+	}
+	return ""
+}
+
+// wrapper forwards the lifecycle hooks of the plugin it wraps.
+type wrapper struct {
+	plugin Plugin
+}
+
+func (w wrapper) Setup(ctx context.Context) error {
+	if lifecycle, ok := w.plugin.(Lifecycle); ok {
+		return lifecycle.Setup(ctx)
+	}
+	return nil
+}
+
+func (w wrapper) Teardown() error {
+	if lifecycle, ok := w.plugin.(Lifecycle); ok {
+		return lifecycle.Teardown()
+	}
+	return nil
+}
+
+type namedPlugin struct {
+	wrapper
+	name string
+}
+
+// Named gives plugin a name, such as a [Transformer] returned by a
+// function. Lifecycle hooks of plugin are kept.
+func Named(name string, plugin Plugin) Plugin {
+	return namedPlugin{wrapper: wrapper{plugin: plugin}, name: name}
+}
+
+func (p namedPlugin) Name() string { return p.name }
+
+func (p namedPlugin) Transform(ctx context.Context, files *[]File, store *Store) error {
+	return p.plugin.Transform(ctx, files, store)
+}
+
+func (p namedPlugin) fileTransformer() (FileTransformer, bool) {
+	return asFileTransformer(p.plugin)
+}
+
+// A plugin added to the builder.
+type stage struct {
+	index  int
+	name   string // empty if the plugin has no name
+	plugin Plugin
+	// Applies the plugin, which for file transformers
+	// is done concurrently.
+	transform ContextTransformer
+}
+
+// Identifies the stage in errors.
+func (s stage) String() string {
+	if s.name == "" {
+		return fmt.Sprintf("transformer at index %d", s.index)
+	}
+	return fmt.Sprintf("transformer %q at index %d", s.name, s.index)
+}
+
+// setupPlugins calls Setup on every plugin implementing [Lifecycle].
+// It returns a function that tears down the plugins that were set
+// up, which must be called even if setupPlugins fails.
+func (b *Builder) setupPlugins(ctx context.Context) (teardown func() error, err error) {
+	var ready []Lifecycle
+	teardown = func() error {
+		var errs []error
+		for i := len(ready) - 1; i >= 0; i-- {
+			if err := ready[i].Teardown(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for i, stage := range b.transformers {
+		lifecycle, ok := stage.plugin.(Lifecycle)
+		if !ok {
+			continue
+		}
+		b.log.Debug("Setting up transformer", "index", i, "name", stage.name)
+		if err := lifecycle.Setup(ctx); err != nil {
+			return teardown, fmt.Errorf("setup of %s failed: %w", stage, err)
+		}
+		ready = append(ready, lifecycle)
+	}
+	return teardown, nil
+}
@@ -0,0 +1,86 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// A plugin recording calls to its lifecycle hooks
+type testPlugin struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (p testPlugin) Name() string { return p.name }
+
+func (p testPlugin) Setup(ctx context.Context) error {
+	*p.calls = append(*p.calls, "setup "+p.name)
+	return nil
+}
+
+func (p testPlugin) Transform(ctx context.Context, files *[]File, store *Store) error {
+	*p.calls = append(*p.calls, "transform "+p.name)
+	return p.err
+}
+
+func (p testPlugin) Teardown() error {
+	*p.calls = append(*p.calls, "teardown "+p.name)
+	return nil
+}
+
+func TestPluginLifecycle(t *testing.T) {
+	var calls []string
+	errFailed := errors.New("failed")
+
+	b := NewBuilder()
+	b.SourceFS(fstest.MapFS{"index.html": {Data: []byte("index")}})
+	b.UsePlugin(testPlugin{name: "first", calls: &calls})
+	b.Use(func(files *[]File, store *Store) error { return nil })
+	b.UsePlugin(testPlugin{name: "second", calls: &calls, err: errFailed})
+	b.UsePlugin(testPlugin{name: "third", calls: &calls})
+
+	_, err := b.Run(context.Background())
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("expected errFailed, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), `transformer "second" at index 2 failed`) {
+		t.Errorf("expected error to name the transformer, got: %v", err)
+	}
+
+	want := []string{
+		"setup first", "setup second", "setup third",
+		"transform first", "transform second",
+		"teardown third", "teardown second", "teardown first",
+	}
+	if !slices.Equal(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	b := NewBuilder()
+	b.SourceFS(fstest.MapFS{"index.html": {Data: []byte("index")}})
+	b.UsePlugin(Named("exclaim", FileTransformer(func(file *File, store *Store) error {
+		file.SetContent(append(file.Content(), '!'))
+		return nil
+	})))
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(result.Files[0].Content()) != "index!" {
+		t.Errorf("got %q, want %q", result.Files[0].Content(), "index!")
+	}
+	if name := result.Report.Transformers[0].Name; name != "exclaim" {
+		t.Errorf("got name %q in report, want %q", name, "exclaim")
+	}
+	if _, ok := asFileTransformer(b.transformers[0].plugin); !ok {
+		t.Error("named file transformer is no longer applied per file")
+	}
+}
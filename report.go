@@ -0,0 +1,135 @@
+package medusa
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BuildReport describes a completed build. It is returned by
+// [Builder.BuildWithReport] and in [Result], and can be written as
+// JSON after every build by setting Config.ReportFile.
+type BuildReport struct {
+	// When the build started.
+	Started time.Time `json:"started"`
+	// How long the build took in total.
+	Duration time.Duration `json:"duration"`
+
+	// The paths of the files read from the source.
+	Inputs []string `json:"inputs"`
+	// The files produced by the build, in the order they were written.
+	Outputs []OutputReport `json:"outputs"`
+	// The transformers, in the order they were applied.
+	Transformers []TransformerReport `json:"transformers"`
+}
+
+// OutputReport describes a single file produced by a build.
+type OutputReport struct {
+	Path string `json:"path"`
+	// The path the file was read from, if it was
+	// read from the source.
+	Source string `json:"source,omitempty"`
+	URL    string `json:"url"`
+	// Whether the file was created by a transformer,
+	// rather than read from the source.
+	Virtual bool  `json:"virtual,omitempty"`
+	Size    int64 `json:"size"`
+	// The hex encoded SHA-256 hash of the content.
+	Hash string `json:"hash"`
+}
+
+// TransformerReport describes a single transformer of a build.
+type TransformerReport struct {
+	// The position of the transformer in the stack.
+	Index int `json:"index"`
+	// The name of the transformer, if it implements [Namer].
+	Name     string        `json:"name,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	// The paths of files the transformer added to the file slice.
+	// A file whose path was changed is reported as removed
+	// under its old path and added under its new one.
+	Added []string `json:"added,omitempty"`
+	// The paths of files the transformer removed from the file slice.
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Returns the report of the last successful build or run, or nil if
+// there was none. It does not wait for a build in progress, and when
+// builds run concurrently, it may be the report of another build than
+// the caller's. Use [Builder.BuildWithReport] or [Result] to get the
+// report of a given build.
+func (b *Builder) Report() *BuildReport {
+	return b.report.Load()
+}
+
+// filePaths returns the paths of files, in order.
+func filePaths(files []File) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+// diffPaths returns the paths in after that are not in before, and
+// the paths in before that are not in after. Paths that occur more
+// than once are counted, so a duplicated path is reported as added.
+func diffPaths(before []string, after []string) (added []string, removed []string) {
+	counts := make(map[string]int, len(before))
+	for _, path := range before {
+		counts[path]++
+	}
+	for _, path := range after {
+		if counts[path] > 0 {
+			counts[path]--
+		} else {
+			added = append(added, path)
+		}
+	}
+	for _, path := range before {
+		if counts[path] > 0 {
+			counts[path]--
+			removed = append(removed, path)
+		}
+	}
+	return added, removed
+}
+
+// outputReports describes files as outputs. Files that
+// were not loaded are hashed without loading them.
+func outputReports(files []File) ([]OutputReport, error) {
+	outputs := make([]OutputReport, len(files))
+	for i := range files {
+		hash, err := files[i].contentHash()
+		if err != nil {
+			return nil, err
+		}
+		outputs[i] = OutputReport{
+			Path:    files[i].Path,
+			Source:  files[i].sourcePath,
+			URL:     files[i].URL(),
+			Virtual: files[i].Virtual(),
+			Size:    files[i].size(),
+			Hash:    hash,
+		}
+	}
+	return outputs, nil
+}
+
+// writeReport writes report as indented JSON to path.
+func writeReport(path string, report *BuildReport) error {
+	data, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode build report: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for build report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write build report %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package medusa
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildReport(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"index.md":  "index",
+		"style.css": "body {}",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir, ReportFile: "report.json"})
+	b.Source("src")
+	b.Destination("build")
+	b.UseFile(func(file *File, store *Store) error {
+		if strings.HasSuffix(file.Path, ".md") {
+			file.Path = strings.TrimSuffix(file.Path, ".md") + ".html"
+		}
+		return nil
+	})
+	b.Use(func(files *[]File, store *Store) error {
+		*files = append(*files, File{Path: "feed.xml"})
+		return nil
+	})
+
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report BuildReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(report.Inputs, []string{"index.md", "style.css"}) {
+		t.Errorf("unexpected inputs: %v", report.Inputs)
+	}
+	if len(report.Outputs) != 3 {
+		t.Fatalf("expected 3 outputs, got %v", report.Outputs)
+	}
+	if out := report.Outputs[1]; out.Path != "style.css" || out.Size != 7 || out.Hash != hashBytes([]byte("body {}")) {
+		t.Errorf("unexpected output: %+v", out)
+	}
+
+	if len(report.Transformers) != 2 {
+		t.Fatalf("expected 2 transformers, got %v", report.Transformers)
+	}
+	first, second := report.Transformers[0], report.Transformers[1]
+	if !slices.Equal(first.Added, []string{"index.html"}) || !slices.Equal(first.Removed, []string{"index.md"}) {
+		t.Errorf("unexpected changes of first transformer: %+v", first)
+	}
+	if !slices.Equal(second.Added, []string{"feed.xml"}) || len(second.Removed) != 0 {
+		t.Errorf("unexpected changes of second transformer: %+v", second)
+	}
+
+	if b.Report() == nil || b.Report().Duration != report.Duration {
+		t.Errorf("expected Report to return the written report, got %+v", b.Report())
+	}
+}
+
+func TestBuildWithReport(t *testing.T) {
+	dir := makeSource(t, map[string]string{"index.html": "index"})
+	b := NewBuilder(Config{WorkingDir: dir, AllowOverwrite: true})
+	b.Source("src")
+	b.Destination("build")
+
+	first, err := b.BuildWithReport(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := b.BuildWithReport(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first == second {
+		t.Error("expected every build to return its own report")
+	}
+	if len(first.Outputs) != 1 || first.Outputs[0].Path != "index.html" {
+		t.Errorf("unexpected outputs: %+v", first.Outputs)
+	}
+	if first.Outputs[0].Hash != hashBytes([]byte("index")) {
+		t.Errorf("unexpected hash %s", first.Outputs[0].Hash)
+	}
+	if b.Report() != second {
+		t.Error("expected Report to return the report of the last build")
+	}
+}
@@ -0,0 +1,71 @@
+package medusa
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sourceDateEpoch returns the time in the SOURCE_DATE_EPOCH environment
+// variable, or the Unix epoch if it is not set or invalid.
+// See https://reproducible-builds.org/specs/source-date-epoch/.
+func sourceDateEpoch(log *slog.Logger) time.Time {
+	value := os.Getenv("SOURCE_DATE_EPOCH")
+	if value == "" {
+		return time.Unix(0, 0).UTC()
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Warn("Ignoring invalid SOURCE_DATE_EPOCH", "value", value, "error", err)
+		return time.Unix(0, 0).UTC()
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// sortFiles orders files by path, keeping the
+// order of files with the same path.
+func sortFiles(files []File) {
+	slices.SortStableFunc(files, func(a, b File) int {
+		return strings.Compare(filepath.ToSlash(a.Path), filepath.ToSlash(b.Path))
+	})
+}
+
+// normalizeFiles prepares files for a reproducible build. It
+// orders them by path, and replaces their file info so they
+// are written with the same mode and modification time on
+// every build.
+func (b *Builder) normalizeFiles(files []File) {
+	sortFiles(files)
+	for i := range files {
+		file := &files[i]
+		mode := fs.FileMode(0644)
+		if fileMode(file)&0111 != 0 {
+			mode = 0755
+		}
+		file.FileInfo = resultFileInfo{
+			name:    filepath.Base(file.Path),
+			size:    file.size(),
+			mode:    mode,
+			modTime: b.timestamp,
+		}
+	}
+}
+
+// normalizeDirTimes sets the modification time of every
+// directory in dir, including dir itself, to modTime.
+func normalizeDirTimes(dir string, modTime time.Time) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return os.Chtimes(path, modTime, modTime)
+	})
+}
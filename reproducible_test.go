@@ -0,0 +1,87 @@
+package medusa
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReproducibleBuild(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	build := func(modTime time.Time) ([]byte, string) {
+		dir := makeSource(t, map[string]string{
+			"index.html":      "index",
+			"blog/post.html":  "post",
+			"scripts/run.sh":  "#!/bin/sh",
+			"assets/site.css": "css",
+		})
+		err := filepath.WalkDir(filepath.Join(dir, "src"), func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			return os.Chtimes(path, modTime, modTime)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Join(dir, "src", "scripts", "run.sh"), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		var archive bytes.Buffer
+		b := NewBuilder(Config{WorkingDir: dir, Reproducible: true, Timestamp: timestamp})
+		b.Source("src")
+		b.Output(func() (Sink, error) { return NewTarGzSink(&archive), nil })
+		// Reverses the order of the files, which the build undoes.
+		b.Use(func(files *[]File, store *Store) error {
+			for i, j := 0, len(*files)-1; i < j; i, j = i+1, j-1 {
+				(*files)[i], (*files)[j] = (*files)[j], (*files)[i]
+			}
+			return nil
+		})
+		if err := b.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		b.Output(nil)
+		b.Destination("build")
+		if err := b.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return archive.Bytes(), filepath.Join(dir, "build")
+	}
+
+	first, dest := build(time.Now().Add(-time.Hour))
+	second, _ := build(time.Now())
+	if !bytes.Equal(first, second) {
+		t.Error("expected identical archives")
+	}
+
+	for path, mode := range map[string]os.FileMode{
+		"index.html":     0644,
+		"scripts/run.sh": 0755,
+		"blog":           os.ModeDir,
+	} {
+		info, err := os.Stat(filepath.Join(dest, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.IsDir() && info.Mode().Perm() != mode {
+			t.Errorf("%s: expected mode %v, got %v", path, mode, info.Mode().Perm())
+		}
+		if !info.ModTime().Equal(timestamp) {
+			t.Errorf("%s: expected modification time %v, got %v", path, timestamp, info.ModTime())
+		}
+	}
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	b := NewBuilder()
+	if want := time.Unix(1700000000, 0); !b.timestamp.Equal(want) {
+		t.Errorf("expected timestamp %v, got %v", want, b.timestamp)
+	}
+}
@@ -0,0 +1,159 @@
+package medusa
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The outcome of running the transformers, as returned by [Builder.Run].
+type Result struct {
+	// The files as left by the last transformer.
+	Files []File
+
+	// The global store as left by the last transformer.
+	Store Store
+
+	// Describes the run. Its outputs are the resulting files.
+	Report *BuildReport
+}
+
+// FS returns a read-only view of the resulting files, laid out
+// as they would be written to the destination.
+func (r *Result) FS() fs.FS {
+	fsys := resultFS{
+		files: make(map[string]*File, len(r.Files)),
+		dirs:  map[string][]string{".": nil},
+	}
+	for i := range r.Files {
+		name := path.Clean(filepath.ToSlash(r.Files[i].Path))
+		fsys.files[name] = &r.Files[i]
+
+		// Register the file in its directory, and every
+		// directory in its parent, up to the root.
+		for name != "." {
+			dir := path.Dir(name)
+			_, known := fsys.dirs[dir]
+			if !slices.Contains(fsys.dirs[dir], name) {
+				fsys.dirs[dir] = append(fsys.dirs[dir], name)
+			}
+			if known {
+				break
+			}
+			name = dir
+		}
+	}
+	return fsys
+}
+
+// resultFS is an [fs.FS] of the files in a [Result].
+type resultFS struct {
+	files map[string]*File
+	// Maps directories to the names of their entries.
+	dirs map[string][]string
+}
+
+func (fsys resultFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if file, ok := fsys.files[name]; ok {
+		// The file is not loaded, so the file system
+		// can be read from several goroutines at once.
+		r, err := file.reader()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		defer r.Close()
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &resultFile{info: fsys.fileInfo(name), Reader: bytes.NewReader(content)}, nil
+	}
+	if entries, ok := fsys.dirs[name]; ok {
+		dir := &resultDir{info: resultFileInfo{name: path.Base(name), mode: fs.ModeDir | 0755}}
+		for _, entry := range entries {
+			dir.entries = append(dir.entries, fs.FileInfoToDirEntry(fsys.stat(entry)))
+		}
+		slices.SortFunc(dir.entries, func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+		return dir, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (fsys resultFS) stat(name string) fs.FileInfo {
+	if _, ok := fsys.files[name]; ok {
+		return fsys.fileInfo(name)
+	}
+	return resultFileInfo{name: path.Base(name), mode: fs.ModeDir | 0755}
+}
+
+func (fsys resultFS) fileInfo(name string) resultFileInfo {
+	file := fsys.files[name]
+	info := resultFileInfo{
+		name: path.Base(name),
+		size: file.size(),
+		mode: fileMode(file),
+	}
+	if file.FileInfo != nil {
+		info.modTime = file.FileInfo.ModTime()
+	}
+	return info
+}
+
+type resultFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi resultFileInfo) Name() string       { return fi.name }
+func (fi resultFileInfo) Size() int64        { return fi.size }
+func (fi resultFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi resultFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi resultFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi resultFileInfo) Sys() any           { return nil }
+
+type resultFile struct {
+	info resultFileInfo
+	*bytes.Reader
+}
+
+func (f *resultFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *resultFile) Close() error               { return nil }
+
+type resultDir struct {
+	info    resultFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *resultDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *resultDir) Close() error               { return nil }
+
+func (d *resultDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *resultDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
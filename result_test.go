@@ -0,0 +1,76 @@
+package medusa
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"index.html":         "index",
+		"blog/post.html":     "post",
+		"blog/2024/old.html": "old",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Use(func(files *[]File, store *Store) error {
+		(*store)["ran"] = true
+		return nil
+	})
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(result.Files))
+	}
+	if result.Store["ran"] != true {
+		t.Errorf("expected store to be returned, got %v", result.Store)
+	}
+
+	fsys := result.FS()
+	if err := fstest.TestFS(fsys, "index.html", "blog/post.html", "blog/2024/old.html"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := fs.ReadFile(fsys, "blog/post.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "post" {
+		t.Errorf("got %q, want %q", content, "post")
+	}
+}
+
+func TestRunSourceFS(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := NewBuilder()
+	b.SourceFS(fstest.MapFS{
+		"index.md":     {Data: []byte("---\ntitle: Home\n---\nhello"), ModTime: modTime},
+		"css/site.css": {Data: []byte("body {}"), Mode: 0600},
+	})
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(result.Files))
+	}
+
+	css, index := result.Files[0], result.Files[1]
+	if css.Path != filepath.Join("css", "site.css") || css.FileInfo.Mode().Perm() != 0600 {
+		t.Errorf("unexpected file: %v, mode %v", css.Path, css.FileInfo.Mode())
+	}
+	if index.Frontmatter["title"] != "Home" || string(index.Content()) != "hello" {
+		t.Errorf("unexpected frontmatter %v or content %q", index.Frontmatter, index.Content())
+	}
+	if !index.FileInfo.ModTime().Equal(modTime) {
+		t.Errorf("got mod time %v, want %v", index.FileInfo.ModTime(), modTime)
+	}
+}
@@ -0,0 +1,278 @@
+package medusa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The path the live reload script listens on for rebuilds.
+const liveReloadPath = "/_medusa/livereload"
+
+// Injected into every html response. It reloads the page
+// when the server sends an event after a rebuild.
+const liveReloadScript = `<script>new EventSource("` + liveReloadPath + `").onmessage = function () { location.reload(); };</script>`
+
+// Serve watches the source directory like [Builder.Watch], and
+// serves the built site over HTTP on addr while doing so. It is
+// meant for development:
+//   - "/about" serves "about.html" or "about/index.html".
+//   - "404.html" in the site, if any, is served for missing files.
+//   - A script is injected into html responses that reloads the page
+//     after every successful rebuild.
+//
+// The site is built into the destination and served from there. When
+// no destination is defined, or a sink is set with [Builder.Output],
+// the site is built in memory with [Builder.Run] instead, and neither
+// the destination nor the sink is written.
+//
+// Serve blocks until ctx is done, and then returns nil.
+func (b *Builder) Serve(ctx context.Context, addr string) error {
+	inMemory := b.openSink != nil || b.destination == ""
+	if !inMemory && !b.autoConfirm {
+		return fmt.Errorf("serving requires Config.AllowOverwrite: %w", ErrDestinationExists)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	var s *devServer
+	rebuild := b.rebuild
+	if inMemory {
+		s = newDevServer(b, (&Result{}).FS())
+		rebuild = s.rebuildInMemory
+	} else {
+		s = newDevServer(b, os.DirFS(b.destination))
+	}
+	server := &http.Server{Handler: s}
+	serveErr := make(chan error, 1)
+	go func() {
+		b.log.Info("Serving site", "url", "http://"+listener.Addr().String(), "destination", b.destination, "in_memory", inMemory)
+		serveErr <- server.Serve(listener)
+	}()
+
+	watchErr := b.watch(ctx, func(ctx context.Context) bool {
+		if !rebuild(ctx) {
+			return false
+		}
+		s.reload()
+		return true
+	})
+
+	s.close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		b.log.Warn("Failed to shut down server", "error", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return watchErr
+}
+
+// devServer serves the site built by a builder and
+// notifies live reload clients after rebuilds.
+type devServer struct {
+	b *Builder
+
+	mu sync.Mutex
+	// The files being served. It is replaced after
+	// every rebuild when building in memory.
+	fsys    fs.FS
+	clients map[chan struct{}]struct{}
+	done    chan struct{}
+}
+
+func newDevServer(b *Builder, fsys fs.FS) *devServer {
+	return &devServer{
+		b:       b,
+		fsys:    fsys,
+		clients: make(map[chan struct{}]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// rebuildInMemory runs the transformers, serves the resulting
+// files from then on and reports whether the run succeeded.
+func (s *devServer) rebuildInMemory(ctx context.Context) bool {
+	result, err := s.b.Run(ctx)
+	if err == nil {
+		// Loads the files, so they are served as built even if
+		// the source changes before the next rebuild.
+		for i := range result.Files {
+			result.Files[i].load()
+			if err = result.Files[i].err; err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.b.log.Error("Rebuild failed", "error", err)
+		}
+		return false
+	}
+	s.mu.Lock()
+	s.fsys = result.FS()
+	s.mu.Unlock()
+	s.b.log.Info("Rebuild finished", "files", len(result.Files))
+	return true
+}
+
+// files returns the files being served.
+func (s *devServer) files() fs.FS {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fsys
+}
+
+// reload tells every connected page to reload.
+func (s *devServer) reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		select {
+		case client <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+}
+
+// close disconnects all live reload clients.
+func (s *devServer) close() {
+	close(s.done)
+}
+
+func (s *devServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.b.log.Debug("Serving request", "method", r.Method, "path", r.URL.Path)
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.URL.Path == liveReloadPath {
+		s.serveEvents(w, r)
+		return
+	}
+
+	fsys := s.files()
+	urlPath := path.Clean("/" + r.URL.Path)
+	if info, err := fs.Stat(fsys, fsPath(urlPath)); err == nil && info.IsDir() && !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, urlPath+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	name, ok := resolve(fsys, urlPath)
+	if !ok {
+		name, ok = resolve(fsys, "/404.html")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s.serveFile(w, r, fsys, name, http.StatusNotFound)
+		return
+	}
+	s.serveFile(w, r, fsys, name, http.StatusOK)
+}
+
+// fsPath maps a clean url path to a name in the served file system.
+func fsPath(urlPath string) string {
+	if urlPath == "/" {
+		return "."
+	}
+	return strings.TrimPrefix(urlPath, "/")
+}
+
+// resolve maps a clean url path to a file in fsys.
+func resolve(fsys fs.FS, urlPath string) (string, bool) {
+	candidates := []string{urlPath, urlPath + ".html", path.Join(urlPath, "index.html")}
+	for _, candidate := range candidates {
+		name := fsPath(candidate)
+		if info, err := fs.Stat(fsys, name); err == nil && info.Mode().IsRegular() {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (s *devServer) serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string, status int) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ext := strings.ToLower(path.Ext(name))
+	if ext == ".html" || ext == ".htm" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write(injectLiveReload(content))
+		return
+	}
+
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		w.Write(content)
+		return
+	}
+	// Sets the content type based on the extension.
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+// serveEvents keeps a server-sent event stream open,
+// and sends an event on it after every rebuild.
+func (s *devServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-client:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// injectLiveReload inserts the live reload script
+// before "</body>", or at the end if there is none.
+func injectLiveReload(content []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(content), []byte("</body>"))
+	if i < 0 {
+		i = len(content)
+	}
+	injected := make([]byte, 0, len(content)+len(liveReloadScript))
+	injected = append(injected, content[:i]...)
+	injected = append(injected, liveReloadScript...)
+	return append(injected, content[i:]...)
+}
@@ -0,0 +1,108 @@
+package medusa
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDevServer(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"index.html":      "<html><body>index</body></html>",
+		"about.html":      "about",
+		"blog/index.html": "blog",
+		"404.html":        "missing",
+		"style.css":       "body {}",
+	})
+
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Destination("build")
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/", http.StatusOK, "text/html; charset=utf-8", "<html><body>index" + liveReloadScript + "</body></html>"},
+		{"/about", http.StatusOK, "text/html; charset=utf-8", "about" + liveReloadScript},
+		{"/blog/", http.StatusOK, "text/html; charset=utf-8", "blog" + liveReloadScript},
+		{"/blog", http.StatusMovedPermanently, "", ""},
+		{"/style.css", http.StatusOK, "text/css; charset=utf-8", "body {}"},
+		{"/nope", http.StatusNotFound, "text/html; charset=utf-8", "missing" + liveReloadScript},
+	}
+
+	s := newDevServer(b, os.DirFS(filepath.Join(dir, "build")))
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+			if tt.contentType != "" && w.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("got content type %q, want %q", w.Header().Get("Content-Type"), tt.contentType)
+			}
+			if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+				t.Errorf("got body %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestDevServerInMemory(t *testing.T) {
+	dir := makeSource(t, map[string]string{
+		"index.html": "index",
+		"about.html": "about",
+	})
+
+	sink := NewMemorySink()
+	b := NewBuilder(Config{WorkingDir: dir})
+	b.Source("src")
+	b.Output(sink.Open)
+
+	s := newDevServer(b, (&Result{}).FS())
+	if !s.rebuildInMemory(context.Background()) {
+		t.Fatal("rebuild failed")
+	}
+	// The source changing does not change what is served until the next rebuild.
+	if err := os.WriteFile(filepath.Join(dir, "src", "about.html"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", http.StatusOK, "index" + liveReloadScript},
+		{"/about", http.StatusOK, "about" + liveReloadScript},
+		{"/etc/hostname", http.StatusNotFound, ""},
+		{"/../src/about.html", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+				t.Errorf("got body %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+	if len(sink.Files) != 0 {
+		t.Errorf("got %d files in the sink, want none", len(sink.Files))
+	}
+}
@@ -0,0 +1,239 @@
+package medusa
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Sink receives the files of a build in place of the destination
+// directory. Set it with [Builder.Output].
+type Sink interface {
+	// Write stores a single file. It is called once
+	// for every file, in the order of the file slice.
+	Write(file *File) error
+
+	// Close is called once after every file was written
+	// successfully. It is not called if the build fails.
+	Close() error
+}
+
+// Sets the function opening the sink the files are written to,
+// replacing the destination directory. It is called once per build,
+// after the transformers succeeded, so every build writes to a sink
+// of its own. When a sink is set, no destination has to be defined,
+// and Config.AllowOverwrite has no effect. A nil open writes to the
+// destination again.
+//
+// For example, to write an archive into a buffer on every build:
+//
+//	b.Output(func() (medusa.Sink, error) {
+//		buf.Reset()
+//		return medusa.NewZipSink(&buf), nil
+//	})
+func (b *Builder) Output(open func() (Sink, error)) {
+	b.log.Debug("Setting output sink", "set", open != nil)
+	b.openSink = open
+}
+
+// fileMode returns the permissions of file, defaulting to 0644
+// if it has none, as is the case for some [fs.FS] sources.
+func fileMode(file *File) fs.FileMode {
+	if file.FileInfo != nil && file.FileInfo.Mode().Perm() != 0 {
+		return file.FileInfo.Mode().Perm()
+	}
+	return 0644
+}
+
+// fileModTime returns the modification time of file, defaulting to now.
+func fileModTime(file *File) time.Time {
+	if file.FileInfo != nil {
+		return file.FileInfo.ModTime()
+	}
+	return time.Now()
+}
+
+type dirSink struct {
+	dir string
+	// Whether the mode and modification time of the file info
+	// are applied exactly, regardless of the umask and of
+	// existing files.
+	exact bool
+}
+
+// NewDirSink returns a [Sink] that writes files into dir, creating
+// it if needed. Unlike the destination directory, existing files in
+// dir are kept unless they are overwritten.
+func NewDirSink(dir string) Sink {
+	return dirSink{dir: dir}
+}
+
+func (s dirSink) Write(file *File) error {
+	writePath := filepath.Join(s.dir, file.Path)
+	writeDir := filepath.Dir(writePath)
+
+	err := os.MkdirAll(writeDir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", writeDir, err)
+	}
+
+	err = writeFile(writePath, file)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", writePath, err)
+	}
+
+	if s.exact {
+		if err := os.Chmod(writePath, fileMode(file)); err != nil {
+			return fmt.Errorf("failed to set mode of file %s: %w", writePath, err)
+		}
+		modTime := fileModTime(file)
+		if err := os.Chtimes(writePath, modTime, modTime); err != nil {
+			return fmt.Errorf("failed to set modification time of file %s: %w", writePath, err)
+		}
+	}
+	return nil
+}
+
+func (s dirSink) Close() error { return nil }
+
+// writeFile writes the content of file to path,
+// streaming it from its source if it was not loaded.
+func writeFile(path string, file *File) error {
+	r, err := file.reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode(file))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type zipSink struct {
+	w *zip.Writer
+}
+
+// NewZipSink returns a [Sink] that writes files into a zip
+// archive on w. Closing the sink does not close w.
+func NewZipSink(w io.Writer) Sink {
+	return zipSink{w: zip.NewWriter(w)}
+}
+
+func (s zipSink) Write(file *File) error {
+	header := &zip.FileHeader{
+		Name:     filepath.ToSlash(file.Path),
+		Method:   zip.Deflate,
+		Modified: fileModTime(file),
+	}
+	header.SetMode(fileMode(file))
+
+	r, err := file.reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := s.w.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to zip archive: %w", file.Path, err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to write %s to zip archive: %w", file.Path, err)
+	}
+	return nil
+}
+
+func (s zipSink) Close() error {
+	return s.w.Close()
+}
+
+type tarGzSink struct {
+	gz  *gzip.Writer
+	tar *tar.Writer
+}
+
+// NewTarGzSink returns a [Sink] that writes files into a gzip
+// compressed tar archive on w. Closing the sink does not close w.
+func NewTarGzSink(w io.Writer) Sink {
+	gz := gzip.NewWriter(w)
+	return tarGzSink{gz: gz, tar: tar.NewWriter(gz)}
+}
+
+func (s tarGzSink) Write(file *File) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     filepath.ToSlash(file.Path),
+		Size:     file.size(),
+		Mode:     int64(fileMode(file)),
+		ModTime:  fileModTime(file),
+		Format:   tar.FormatPAX,
+	}
+	r, err := file.reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := s.tar.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to add %s to tar archive: %w", file.Path, err)
+	}
+	if _, err := io.Copy(s.tar, r); err != nil {
+		return fmt.Errorf("failed to write %s to tar archive: %w", file.Path, err)
+	}
+	return nil
+}
+
+func (s tarGzSink) Close() error {
+	if err := s.tar.Close(); err != nil {
+		return err
+	}
+	return s.gz.Close()
+}
+
+// MemorySink is a [Sink] that keeps the written files in memory.
+type MemorySink struct {
+	// Maps slash separated paths to file contents.
+	Files map[string][]byte
+}
+
+// NewMemorySink returns an empty [MemorySink].
+func NewMemorySink() *MemorySink {
+	return &MemorySink{Files: make(map[string][]byte)}
+}
+
+// Open empties the sink and returns it. It can be passed to
+// [Builder.Output], so the sink only holds the files of the
+// last build.
+func (s *MemorySink) Open() (Sink, error) {
+	s.Files = make(map[string][]byte)
+	return s, nil
+}
+
+func (s *MemorySink) Write(file *File) error {
+	r, err := file.reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.Files[filepath.ToSlash(file.Path)] = content
+	return nil
+}
+
+func (s *MemorySink) Close() error { return nil }
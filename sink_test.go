@@ -0,0 +1,126 @@
+package medusa
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+func newSinkBuilder(open func() (Sink, error)) *Builder {
+	b := NewBuilder()
+	b.SourceFS(fstest.MapFS{
+		"index.html":     {Data: []byte("index")},
+		"blog/post.html": {Data: []byte("post"), Mode: 0600},
+	})
+	b.Output(open)
+	return b
+}
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	if err := newSinkBuilder(sink.Open).Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sink.Files) != 2 || string(sink.Files["blog/post.html"]) != "post" {
+		t.Errorf("unexpected files: %v", sink.Files)
+	}
+}
+
+func TestZipSink(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newSinkBuilder(func() (Sink, error) { return NewZipSink(&buf), nil }).Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(r.File))
+	}
+	f, err := r.Open("blog/post.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(f)
+	if string(content) != "post" {
+		t.Errorf("got %q, want %q", content, "post")
+	}
+	// Files are written in walk order, so "blog/post.html" comes first.
+	if r.File[0].Mode().Perm() != 0600 || r.File[1].Mode().Perm() != 0644 {
+		t.Errorf("got modes %v and %v, want 0600 and 0644", r.File[0].Mode(), r.File[1].Mode())
+	}
+}
+
+func TestTarGzSink(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newSinkBuilder(func() (Sink, error) { return NewTarGzSink(&buf), nil }).Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := tar.NewReader(gz)
+	got := make(map[string]string)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		got[header.Name] = string(content)
+	}
+	if len(got) != 2 || got["index.html"] != "index" || got["blog/post.html"] != "post" {
+		t.Errorf("unexpected files: %v", got)
+	}
+}
+
+func TestSinkBuildTwice(t *testing.T) {
+	source := fstest.MapFS{
+		"index.html":     {Data: []byte("index")},
+		"blog/post.html": {Data: []byte("post")},
+	}
+	var buf bytes.Buffer
+	b := NewBuilder()
+	b.SourceFS(source)
+	b.Output(func() (Sink, error) {
+		buf.Reset()
+		return NewZipSink(&buf), nil
+	})
+
+	for i := range 2 {
+		if err := b.Build(); err != nil {
+			t.Fatalf("build %d: unexpected error: %v", i, err)
+		}
+		r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("build %d: %v", i, err)
+		}
+		if len(r.File) != 2 {
+			t.Errorf("build %d: expected 2 files, got %d", i, len(r.File))
+		}
+	}
+
+	memory := NewMemorySink()
+	b.Output(memory.Open)
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delete(source, "blog/post.html")
+	if err := b.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := memory.Files["blog/post.html"]; ok || len(memory.Files) != 1 {
+		t.Errorf("expected only the files of the last build, got %v", memory.Files)
+	}
+}
@@ -248,3 +248,77 @@ func TestCollectionValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestCollectionWithNestedPatterns(t *testing.T) {
+	files := []medusa.File{
+		createTestFile(t, "posts/2024/post1.md", "content1", time.Now()),
+		createTestFile(t, "posts/post2.md", "content2", time.Now()),
+		createTestFile(t, "posts/drafts/post3.md", "content3", time.Now()),
+	}
+
+	store := make(medusa.Store)
+	transformer := New(CollectionConfig{
+		Name:     "posts",
+		Patterns: []string{"posts/**/*.md", "!posts/drafts/**"},
+	})
+
+	err := transformer(&files, &store)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	collection := store["Collections"].(Collections)["posts"]
+	if len(collection.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(collection.Files))
+	}
+}
+
+func TestCollectionDefaultSortIgnoresModTime(t *testing.T) {
+	now := time.Now()
+	files := []medusa.File{
+		createTestFile(t, "posts/b.md", "b", now),
+		createTestFile(t, "posts/a.md", "a", now.Add(-time.Hour)),
+		createTestFile(t, "posts/c.md", "c", now.Add(time.Hour)),
+		createTestFile(t, "posts/d.md", "d", now),
+	}
+	files[0].Frontmatter["date"] = "2024-03-01"
+	files[1].Frontmatter["date"] = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	files[2].Frontmatter["date"] = "2024-03-01"
+
+	store := make(medusa.Store)
+	transformer := New(CollectionConfig{
+		Name:     "posts",
+		Patterns: []string{"posts/*.md"},
+	})
+
+	if err := transformer(&files, &store); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	collection := store["Collections"].(Collections)["posts"]
+	var order []string
+	for _, file := range collection.Files {
+		order = append(order, file.Frontmatter["title"].(string))
+	}
+	want := "posts/a.md,posts/c.md,posts/b.md,posts/d.md"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("expected order %s, got %s", want, got)
+	}
+}
+
+func TestCollectionLinks(t *testing.T) {
+	files := []medusa.File{
+		createTestFile(t, "blog/first.html", "first", time.Now()),
+	}
+	store := make(medusa.Store)
+
+	transformer := New(CollectionConfig{Name: "blog", Patterns: []string{"blog/*.html"}})
+	if err := transformer(&files, &store); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file := store["Collections"].(Collections)["blog"].Files[0]
+	if file.Path != "blog/first.html" || file.URL != "/blog/first.html" {
+		t.Errorf("expected path and URL of the file, got %q and %q", file.Path, file.URL)
+	}
+}
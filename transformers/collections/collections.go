@@ -4,6 +4,8 @@ import (
 	"errors"
 	"path/filepath"
 	"slices"
+	"strings"
+	"time"
 
 	"git.sr.ht/~relay/medusa"
 )
@@ -18,7 +20,9 @@ type CollectionConfig struct {
 	// The name of the collection.
 	Name string
 	// Glob pattern for files to add in
-	// collection.
+	// collection. See [medusa.Glob] for
+	// the syntax, and [medusa.MatchAny]
+	// for how patterns are combined.
 	Patterns []string
 
 	// Store defines the data to add
@@ -28,6 +32,8 @@ type CollectionConfig struct {
 	// 0 if a == b,
 	// -1 if a < b,
 	// +1 if a > b.
+	//
+	// Defaults to [ByDate].
 	SortBy func(a medusa.File, b medusa.File) int
 
 	FilterFunc func(file medusa.File) bool
@@ -48,6 +54,15 @@ type CollectionConfig struct {
 type File struct {
 	Frontmatter map[string]any
 
+	// The output path, source path and URL of the file, as
+	// described at [medusa.File]. They are taken when the
+	// collection is made, and the builder updates them to those
+	// of the file once later transformers, such as a markdown
+	// renderer, renamed it (see [Collections.ResolveFiles]).
+	Path       string
+	SourcePath string
+	URL        string
+
 	// often nil
 	Content []byte
 
@@ -78,9 +93,7 @@ func defaultCollectionCfg(cfg *CollectionConfig) error {
 		cfg.Store = make(map[string]any)
 	}
 	if cfg.SortBy == nil {
-		cfg.SortBy = func(a medusa.File, b medusa.File) int {
-			return a.FileInfo.ModTime().Compare(b.FileInfo.ModTime())
-		}
+		cfg.SortBy = ByDate
 	}
 	if cfg.FilterFunc == nil {
 		cfg.FilterFunc = func(file medusa.File) bool { return true }
@@ -88,18 +101,51 @@ func defaultCollectionCfg(cfg *CollectionConfig) error {
 	return nil
 }
 
-func fileMatchesPatterns(patterns []string, file medusa.File) (bool, error) {
-	matchOne := false
-	for _, pattern := range patterns {
-		match, err := filepath.Match(pattern, file.Path)
-		if err != nil {
-			return false, err
+// The layouts a "date" in the frontmatter may have,
+// unless it was already parsed as a time.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ByDate orders files by the "date" in their frontmatter, and files
+// with the same date by path. Files without a valid date come first.
+// Unlike modification times, the order is the same on every checkout
+// of the source.
+func ByDate(a medusa.File, b medusa.File) int {
+	dateA, okA := frontmatterDate(a)
+	dateB, okB := frontmatterDate(b)
+	switch {
+	case okA && okB:
+		if c := dateA.Compare(dateB); c != 0 {
+			return c
 		}
-		if match {
-			matchOne = true
+	case okA:
+		return 1
+	case okB:
+		return -1
+	}
+	return strings.Compare(filepath.ToSlash(a.Path), filepath.ToSlash(b.Path))
+}
+
+func frontmatterDate(file medusa.File) (time.Time, bool) {
+	switch date := file.Frontmatter["date"].(type) {
+	case time.Time:
+		return date, true
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, date); err == nil {
+				return t, true
+			}
 		}
 	}
-	return matchOne, nil
+	return time.Time{}, false
+}
+
+func fileMatchesPatterns(patterns []string, file medusa.File) (bool, error) {
+	return medusa.MatchAny(patterns, file.Path)
 }
 
 // Adds a "Collections" key to global store.
@@ -138,6 +184,9 @@ func New(collectionCfgs ...CollectionConfig) medusa.Transformer {
 
 				collectionFiles = append(collectionFiles, File{
 					Frontmatter: file.Frontmatter,
+					Path:        file.Path,
+					SourcePath:  file.SourcePath(),
+					URL:         file.URL(),
 					Content:     contentToInclude,
 					medusaFile:  &file,
 				})
@@ -147,7 +196,7 @@ func New(collectionCfgs ...CollectionConfig) medusa.Transformer {
 			for key, value := range cfg.Store {
 				collectionStore[key] = value
 			}
-			slices.SortFunc(collectionFiles, func(a File, b File) int {
+			slices.SortStableFunc(collectionFiles, func(a File, b File) int {
 				if cfg.DontReverse {
 					return cfg.SortBy(*a.medusaFile, *b.medusaFile)
 				}
@@ -164,3 +213,29 @@ func New(collectionCfgs ...CollectionConfig) medusa.Transformer {
 		return nil
 	}
 }
+
+// ResolveFiles updates the Path and URL of the files in the
+// collections to those of the files with the same source path, so
+// they link to the pages that are written. Files created by
+// transformers, which have no source path, are left as they are.
+// It implements [medusa.FileResolver], so the builder calls it
+// before every transformer.
+func (collections Collections) ResolveFiles(files []medusa.File) {
+	bySource := make(map[string]*medusa.File, len(files))
+	for i := range files {
+		if source := files[i].SourcePath(); source != "" {
+			bySource[source] = &files[i]
+		}
+	}
+	for _, collection := range collections {
+		for i := range collection.Files {
+			entry := &collection.Files[i]
+			file, ok := bySource[entry.SourcePath]
+			if !ok {
+				continue
+			}
+			entry.Path = file.Path
+			entry.URL = file.URL()
+		}
+	}
+}
@@ -3,6 +3,9 @@ Package collections is a medusa transformer that group files
 together in collections.
 
 It stores the collections in a [Collections] type at
-the "Collections" key in the global store.
+the "Collections" key in the global store. Files in collections
+link to the pages that are written, even if a later transformer
+renamed them, since the builder updates them before every
+transformer (see [Collections.ResolveFiles]).
 */
 package collections
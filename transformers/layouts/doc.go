@@ -12,6 +12,9 @@ Use the `LayoutPatterns` option to specify glob patterns matching your layout
 and partial template files (e.g., "layouts/*.html", "partials/**\/*.html").
 Use the `ContentPatterns` option to specify glob patterns matching the content
 files that should have layouts applied (e.g., "**\/*.md", "**\/*.html").
+Patterns support "**" to match any number of directories, brace
+alternatives such as "*.{md,html}", and negation with a leading "!"
+(see [medusa.Glob]).
 
 # Layout Selection
 
@@ -26,7 +29,9 @@ For each content file:
 
 Within your layout and partial templates, you have access to the following data:
   - `{{ .File }}`: The medusa.File struct for the content file being processed.
-    Access frontmatter via `{{ .File.Frontmatter.YourKey }}`.
+    Access frontmatter via `{{ .File.Frontmatter.YourKey }}`, and link to
+    the page with `{{ .File.URL }}`. `{{ .File.SourcePath }}` is the path
+    the file was read from, even if a transformer renamed it.
   - `{{ .Global }}`: The global medusa.Store, containing site-wide data.
   - `{{ .Content }}`: The pre-rendered content of the input file, available as
     `template.HTML`. This is typically the output of a preceding transformer
@@ -47,6 +52,10 @@ The "." passes the current data context (`.File`, `.Global`, `.Content`) to the
   - Files matching `ContentPatterns` are processed, and their content is replaced
     with the result of rendering the chosen layout.
   - Files matching neither pattern set are passed through unmodified.
+  - Content files whose layout is missing or fails to execute are left out,
+    and the transformer returns a [medusa.FileError] for every one of them,
+    so all broken pages are reported at once. With medusa.Config.CollectErrors,
+    the build carries on without them.
 
 # Security Note
 
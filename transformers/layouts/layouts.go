@@ -33,6 +33,8 @@ func (e ErrLayoutNotFound) Error() string {
 	return fmt.Sprintf("layout \"%v\" not found (referenced in: %v)", e.layout, e.path)
 }
 
+// Patterns use the syntax of [medusa.Glob], and are combined as
+// described for [medusa.MatchAny].
 type Config struct {
 	// Glob patterns to identify layout and partial files.
 	LayoutPatterns []string
@@ -41,18 +43,7 @@ type Config struct {
 }
 
 func fileMatchesPatterns(patterns []string, file medusa.File) (bool, error) {
-	matchOne := false
-	for _, pattern := range patterns {
-		match, err := filepath.Match(pattern, file.Path)
-		if err != nil {
-			return false, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
-		}
-		if match {
-			matchOne = true
-			break // Found a match, no need to check further patterns
-		}
-	}
-	return matchOne, nil
+	return medusa.MatchAny(patterns, file.Path)
 }
 
 // TemplateData is the data structure accessible from within the templates.
@@ -144,44 +135,56 @@ func New(cfg Config) medusa.Transformer {
 			defaultLayoutName = lastLayoutName
 		}
 
-		// Pass 2: Process content files
+		// Pass 2: Process content files. Files that fail are left
+		// out, and reported together once every file was processed.
 		processedContentFiles := make([]medusa.File, 0, len(contentFiles))
+		var errs []error
 		for _, file := range contentFiles {
-			targetLayoutName := defaultLayoutName
-
-			if name, ok := file.Frontmatter["layout"]; ok {
-				layoutNameStr, ok := name.(string)
-				if !ok {
-					return ErrInvalidLayoutName{path: file.Path}
-				}
-				targetLayoutName = layoutNameStr
+			err := applyLayout(masterTmpl, defaultLayoutName, &file, store)
+			if err != nil {
+				errs = append(errs, &medusa.FileError{Path: file.Path, Err: err})
+				continue
 			}
+			processedContentFiles = append(processedContentFiles, file)
+		}
 
-			if masterTmpl.Lookup(targetLayoutName) == nil {
-				if targetLayoutName == defaultLayoutName {
-					return fmt.Errorf("default layout '%s' (required by '%s') not found or failed to parse", defaultLayoutName, file.Path)
-				}
-				return ErrLayoutNotFound{layout: targetLayoutName, path: file.Path}
-			}
+		*files = append(*files, processedContentFiles...)
 
-			templateData := TemplateData{
-				File:    file,
-				Global:  *store,
-				Content: template.HTML(file.Content()),
-			}
+		return errors.Join(errs...)
+	}
+}
 
-			var newContentBuffer bytes.Buffer
-			err := masterTmpl.ExecuteTemplate(&newContentBuffer, targetLayoutName, templateData)
-			if err != nil {
-				return fmt.Errorf("failed to execute layout '%s' for file '%s': %w", targetLayoutName, file.Path, err)
-			}
+// applyLayout renders file with its layout, or the default layout.
+func applyLayout(masterTmpl *template.Template, defaultLayoutName string, file *medusa.File, store *medusa.Store) error {
+	targetLayoutName := defaultLayoutName
 
-			file.SetContent(newContentBuffer.Bytes())
-			processedContentFiles = append(processedContentFiles, file)
+	if name, ok := file.Frontmatter["layout"]; ok {
+		layoutNameStr, ok := name.(string)
+		if !ok {
+			return ErrInvalidLayoutName{path: file.Path}
+		}
+		targetLayoutName = layoutNameStr
+	}
+
+	if masterTmpl.Lookup(targetLayoutName) == nil {
+		if targetLayoutName == defaultLayoutName {
+			return fmt.Errorf("default layout '%s' (required by '%s') not found or failed to parse", defaultLayoutName, file.Path)
 		}
+		return ErrLayoutNotFound{layout: targetLayoutName, path: file.Path}
+	}
 
-		*files = append(*files, processedContentFiles...)
+	templateData := TemplateData{
+		File:    *file,
+		Global:  *store,
+		Content: template.HTML(file.Content()),
+	}
 
-		return nil
+	var newContentBuffer bytes.Buffer
+	err := masterTmpl.ExecuteTemplate(&newContentBuffer, targetLayoutName, templateData)
+	if err != nil {
+		return fmt.Errorf("failed to execute layout '%s': %w", targetLayoutName, err)
 	}
+
+	file.SetContent(newContentBuffer.Bytes())
+	return nil
 }
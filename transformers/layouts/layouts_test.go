@@ -1,17 +1,22 @@
 package layouts
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"git.sr.ht/~relay/medusa"
+	"git.sr.ht/~relay/medusa/transformers/collections"
+	"git.sr.ht/~relay/medusa/transformers/markdown"
 )
 
 // Helper type for testing FileInfo
@@ -401,3 +406,117 @@ func TestLayoutTransformation_KeepsOtherFiles(t *testing.T) {
 		t.Errorf("Non-processed file content incorrect")
 	}
 }
+
+func TestLayoutTransformation_NestedPatterns(t *testing.T) {
+	layoutFile := makeFile("layouts/default.html", "Layout: {{.Content}}", nil)
+	nested := makeFile("blog/2024/post.html", "Post", nil)
+	topLevel := makeFile("index.html", "Index", nil)
+
+	files := []medusa.File{layoutFile, nested, topLevel}
+	store := medusa.Store{}
+
+	cfg := Config{
+		LayoutPatterns:  []string{"layouts/**"},
+		ContentPatterns: []string{"**/*.html"},
+	}
+
+	transformer := New(cfg)
+	err := transformer(&files, &store)
+	if err != nil {
+		t.Fatalf("Transformation failed: %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files after transformation, got %d", len(files))
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(string(file.Content()), "Layout: ") {
+			t.Errorf("Layout not applied to %s, got %q", file.Path, file.Content())
+		}
+	}
+}
+
+func TestLayoutTransformation_ReportsEveryFailingFile(t *testing.T) {
+	layoutFile := makeFile("layouts/default.html", "Default: {{.Content}}", nil)
+	broken1 := makeFile("a.md", "A", medusa.Store{"layout": "layouts/missing.html"})
+	good := makeFile("b.md", "B", nil)
+	broken2 := makeFile("c.md", "C", medusa.Store{"layout": 123})
+
+	files := []medusa.File{layoutFile, broken1, good, broken2}
+	store := medusa.Store{}
+
+	cfg := Config{
+		LayoutPatterns:  []string{"layouts/*.html"},
+		ContentPatterns: []string{"*.md"},
+	}
+
+	err := New(cfg)(&files, &store)
+
+	var notFound ErrLayoutNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("Expected ErrLayoutNotFound, got: %v", err)
+	}
+	var invalid ErrInvalidLayoutName
+	if !errors.As(err, &invalid) {
+		t.Errorf("Expected ErrInvalidLayoutName, got: %v", err)
+	}
+
+	if len(files) != 1 || files[0].Path != "b.md" {
+		t.Fatalf("Expected only b.md to remain, got %d files", len(files))
+	}
+	if string(files[0].Content()) != "Default: B" {
+		t.Errorf("Unexpected content: %q", files[0].Content())
+	}
+}
+
+func TestLayoutTransformation_URL(t *testing.T) {
+	files := []medusa.File{
+		makeFile("layouts/default.html", `<a href="{{.File.URL}}">{{.Content}}</a>`, nil),
+		makeFile("blog/index.html", "Blog", nil),
+	}
+	store := make(medusa.Store)
+
+	transformer := New(Config{
+		LayoutPatterns:  []string{"layouts/*.html"},
+		ContentPatterns: []string{"blog/*.html"},
+	})
+	if err := transformer(&files, &store); err != nil {
+		t.Fatalf("Transformation failed: %v", err)
+	}
+
+	expected := `<a href="/blog/">Blog</a>`
+	if got := string(files[0].Content()); got != expected {
+		t.Errorf("Expected content %q, got %q", expected, got)
+	}
+}
+
+func TestLayoutTransformation_CollectionLinks(t *testing.T) {
+	b := medusa.NewBuilder()
+	b.SourceFS(fstest.MapFS{
+		"template/default.html": {Data: []byte(`{{range .Global.Collections.Blog.Files}}<a href="{{.URL}}">{{.Frontmatter.title}}</a>{{end}}{{.Content}}`)},
+		"index.html":            {Data: []byte("home")},
+		"blog/post.md":          {Data: []byte("---\ntitle: Post\n---\n# Post")},
+	})
+	b.Use(collections.New(collections.CollectionConfig{
+		Name:     "Blog",
+		Patterns: []string{"blog/*.md"},
+	}))
+	b.UseFile(markdown.NewFile())
+	b.Use(New(Config{
+		LayoutPatterns:  []string{"template/*"},
+		ContentPatterns: []string{"*.html"},
+	}))
+
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	index, err := fs.ReadFile(result.FS(), "index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<a href="/blog/post.html">Post</a>home`
+	if string(index) != expected {
+		t.Errorf("Expected index %q, got %q", expected, index)
+	}
+}
@@ -2,7 +2,7 @@ package markdown
 
 import (
 	"bytes"
-	"io"
+	"errors"
 	"path/filepath"
 	"strings"
 
@@ -10,25 +10,48 @@ import (
 	"github.com/yuin/goldmark"
 )
 
+// Renders ".md" files to html and changes their
+// extension to ".html", one file after another. Files
+// that fail are left out, and reported together as
+// [medusa.FileError]s once every file was rendered.
 func New() medusa.Transformer {
+	return renderAll(NewFile())
+}
+
+// renderAll applies render to every file, keeping
+// going after a file fails.
+func renderAll(render medusa.FileTransformer) medusa.Transformer {
 	return func(files *[]medusa.File, store *medusa.Store) error {
-		var buf bytes.Buffer
-		for i := range *files {
-			file := &((*files)[i])
-			if filepath.Ext(file.Path) != ".md" {
+		rendered := (*files)[:0]
+		var errs []error
+		for _, file := range *files {
+			path := file.Path
+			if err := render(&file, store); err != nil {
+				errs = append(errs, &medusa.FileError{Path: path, Err: err})
 				continue
 			}
-			if err := goldmark.Convert(file.Content(), &buf); err != nil {
-				return err
-			}
-			content, err := io.ReadAll(&buf)
-			if err != nil {
-				return err
-			}
+			rendered = append(rendered, file)
+		}
+		*files = rendered
+		return errors.Join(errs...)
+	}
+}
 
-			file.SetContent(content)
-			file.Path = strings.TrimSuffix(file.Path, ".md") + ".html"
+// Renders ".md" files like [New]. It is a [medusa.FileTransformer],
+// so files are rendered concurrently when added with
+// [medusa.Builder.UseFile].
+func NewFile() medusa.FileTransformer {
+	return func(file *medusa.File, store *medusa.Store) error {
+		if filepath.Ext(file.Path) != ".md" {
+			return nil
 		}
+		var buf bytes.Buffer
+		if err := goldmark.Convert(file.Content(), &buf); err != nil {
+			return err
+		}
+
+		file.SetContent(buf.Bytes())
+		file.Path = strings.TrimSuffix(file.Path, ".md") + ".html"
 		return nil
 	}
 }
@@ -1,7 +1,9 @@
 package markdown
 
 import (
+	"errors"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"git.sr.ht/~relay/medusa"
@@ -52,3 +54,59 @@ func TestMarkdown(t *testing.T) {
 	}
 
 }
+
+func TestMarkdownFile(t *testing.T) {
+	file := medusa.File{Path: "markdown.md"}
+	file.SetContent([]byte("# Hello"))
+	store := make(medusa.Store)
+
+	if err := NewFile()(&file, &store); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(file.Content()); got != "<h1>Hello</h1>\n" {
+		t.Errorf("unexpected render: %q", got)
+	}
+	if file.Path != "markdown.html" {
+		t.Errorf("unexpected path: %s", file.Path)
+	}
+}
+
+func TestMarkdownKeepsGoing(t *testing.T) {
+	files := []medusa.File{{Path: "a.md"}, {Path: "b.md"}, {Path: "c.md"}, {Path: "d.md"}}
+	for i := range files {
+		files[i].SetContent([]byte("# Hello"))
+	}
+	render := NewFile()
+	failing := errors.New("failing")
+	transformer := renderAll(func(file *medusa.File, store *medusa.Store) error {
+		if file.Path == "b.md" || file.Path == "d.md" {
+			return failing
+		}
+		return render(file, store)
+	})
+	store := make(medusa.Store)
+
+	err := transformer(&files, &store)
+	if !errors.Is(err, failing) {
+		t.Fatalf("expected the render error, got %v", err)
+	}
+	var paths []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fileErr *medusa.FileError
+		if !errors.As(e, &fileErr) {
+			t.Fatalf("expected a file error, got %v", e)
+		}
+		paths = append(paths, fileErr.Path)
+	}
+	if !slices.Equal(paths, []string{"b.md", "d.md"}) {
+		t.Errorf("got errors for %v, want b.md and d.md", paths)
+	}
+
+	var rendered []string
+	for _, file := range files {
+		rendered = append(rendered, file.Path)
+	}
+	if !slices.Equal(rendered, []string{"a.html", "c.html"}) {
+		t.Errorf("got files %v, want a.html and c.html", rendered)
+	}
+}
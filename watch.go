@@ -0,0 +1,179 @@
+package medusa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The state of a source file as seen by the watcher.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// Watch builds the site, and then rebuilds it every time files in
+// the source are created, modified or deleted. It polls the source
+// every Config.WatchInterval, and waits for a burst of changes to
+// settle for one more interval before it rebuilds. Errors of the
+// individual builds are logged and do not stop the watcher.
+//
+// Watch blocks until ctx is done, and then returns nil. It requires
+// Config.AllowOverwrite, since every rebuild overwrites the
+// destination.
+func (b *Builder) Watch(ctx context.Context) error {
+	if !b.autoConfirm {
+		return fmt.Errorf("watching requires Config.AllowOverwrite: %w", ErrDestinationExists)
+	}
+	return b.watch(ctx, b.rebuild)
+}
+
+// watch implements [Builder.Watch], building the
+// site with rebuild on start and after changes.
+func (b *Builder) watch(ctx context.Context, rebuild func(context.Context) bool) error {
+	if len(b.mounts) == 0 {
+		return fmt.Errorf("source directory not defined")
+	}
+
+	b.log.Info("Watching source directory", "mounts", b.mounts, "interval", b.watchInterval)
+
+	snapshot := b.snapshotSource()
+	rebuild(ctx)
+
+	ticker := time.NewTicker(b.watchInterval)
+	defer ticker.Stop()
+
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			b.log.Info("Stopped watching source directory", "mounts", b.mounts)
+			return nil
+		case <-ticker.C:
+		}
+
+		next := b.snapshotSource()
+		changed := changedPaths(snapshot, next)
+		snapshot = next
+
+		if len(changed) > 0 {
+			b.log.Debug("Detected changes in source directory", "paths", changed)
+			pending = true
+			continue
+		}
+		if pending {
+			pending = false
+			rebuild(ctx)
+		}
+	}
+}
+
+// rebuild runs a build, logs its outcome and reports whether it succeeded.
+func (b *Builder) rebuild(ctx context.Context) bool {
+	err := b.BuildContext(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			b.log.Error("Rebuild failed", "error", err)
+		}
+		return false
+	}
+	b.log.Info("Rebuild finished", "destination", b.destination)
+	return true
+}
+
+// Identifies a file in a snapshot. Files are told apart by their
+// mount, so changes to overridden files are noticed as well.
+type watchKey struct {
+	mount int
+	path  string // the path in the source tree
+}
+
+// snapshotSource records the size, modification time and mode of
+// every file in every mount. The destination, its staging directories
+// and the cache directory are skipped, in case they are inside a
+// mounted directory, so writing them does not trigger another rebuild.
+// Ignored paths are skipped as well.
+func (b *Builder) snapshotSource() map[watchKey]watchedFile {
+	snapshot := make(map[watchKey]watchedFile)
+	for i, mount := range b.mounts {
+		ignore, err := loadIgnoreRules(mount.fsys, b.ignore, mount.Prefix)
+		if err != nil {
+			// Watch everything, the build reports the error.
+			ignore = nil
+		}
+		err = fs.WalkDir(mount.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Files may disappear while walking,
+				// the next snapshot will pick that up.
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if name != "." && ignore.ignored(mount.treePath(name), d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if mount.Dir != "" && name != "." && (isWithin(mount.sourcePath(name), b.destination) || isStaging(mount.sourcePath(name), b.destination) || isWithin(mount.sourcePath(name), b.cacheDir)) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			snapshot[watchKey{mount: i, path: mount.treePath(name)}] = watchedFile{
+				size:    info.Size(),
+				modTime: info.ModTime(),
+				mode:    info.Mode(),
+			}
+			return nil
+		})
+		if err != nil {
+			b.log.Warn("Failed to scan source directory", "mount", mount, "error", err)
+		}
+	}
+	return snapshot
+}
+
+// changedPaths returns the paths that were created,
+// modified or deleted between two snapshots.
+func changedPaths(prev, next map[watchKey]watchedFile) []string {
+	var changed []string
+	for key, file := range next {
+		if prevFile, ok := prev[key]; !ok || prevFile != file {
+			changed = append(changed, key.path)
+		}
+	}
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			changed = append(changed, key.path)
+		}
+	}
+	return changed
+}
+
+// isWithin reports whether path is dir or inside of it.
+func isWithin(path string, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)))
+}